@@ -28,7 +28,7 @@ func Connect() error {
 	}
 
 	// Auto migrate the models
-	if err := DB.AutoMigrate(&models.ScrapedItem{}); err != nil {
+	if err := DB.AutoMigrate(&models.ScrapedItem{}, &models.ScrapeJob{}, &models.Schedule{}, &models.PriceObservation{}, &models.ItemVersion{}, &models.FrontierURL{}, &models.SiteCredential{}, &models.PageValidator{}); err != nil {
 		log.Printf("Failed to auto migrate: %v", err)
 		return err
 	}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/arkouda/scrape-n-serve/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CredentialRequest represents the expected request body for creating or
+// updating a site credential. The secret fields are stored encrypted and
+// never returned.
+type CredentialRequest struct {
+	Name          string                  `json:"name"`
+	Domain        string                  `json:"domain"`
+	LoginURL      string                  `json:"login_url"`
+	FormSelector  string                  `json:"form_selector"`
+	SuccessText   string                  `json:"success_text"`
+	SuccessCookie string                  `json:"success_cookie"`
+	Auth          *services.AuthOptions   `json:"auth"`
+	LoginFields   map[string]string       `json:"login_fields"`
+	Headers       map[string]string       `json:"headers"`
+	Cookies       []services.CookieOption `json:"cookies"`
+}
+
+// apply copies the request's settings onto a credential and returns its secrets
+func (r CredentialRequest) apply(cred *models.SiteCredential) services.CredentialSecrets {
+	cred.Name = r.Name
+	cred.Domain = r.Domain
+	cred.LoginURL = r.LoginURL
+	cred.FormSelector = r.FormSelector
+	cred.SuccessText = r.SuccessText
+	cred.SuccessCookie = r.SuccessCookie
+
+	return services.CredentialSecrets{
+		Auth:        r.Auth,
+		LoginFields: r.LoginFields,
+		Headers:     r.Headers,
+		Cookies:     r.Cookies,
+	}
+}
+
+// ListCredentials handles the request to list site credentials without their secrets
+func ListCredentials(c *gin.Context) {
+	creds, err := services.ListCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve credentials",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  len(creds),
+		"data":   creds,
+	})
+}
+
+// CreateCredential handles the request to store a new site credential
+func CreateCredential(c *gin.Context) {
+	var req CredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+		return
+	}
+
+	var cred models.SiteCredential
+	secrets := req.apply(&cred)
+
+	if err := services.CreateCredential(&cred, secrets); err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+
+	logger.Info("Stored credential %d (%s)", cred.ID, cred.Name)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   cred,
+	})
+}
+
+// GetCredential handles the request to get a site credential without its secrets
+func GetCredential(c *gin.Context) {
+	cred, ok := loadCredential(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   cred,
+	})
+}
+
+// UpdateCredential handles the request to replace a site credential's
+// settings. Its secrets are only replaced when the request sets any.
+func UpdateCredential(c *gin.Context) {
+	cred, ok := loadCredential(c)
+	if !ok {
+		return
+	}
+
+	var req CredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+		return
+	}
+	secrets := req.apply(&cred)
+
+	if err := services.UpdateCredential(&cred, secrets); err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   cred,
+	})
+}
+
+// DeleteCredential handles the request to remove a site credential
+func DeleteCredential(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid ID format",
+		})
+		return
+	}
+
+	if err := services.DeleteCredential(uint(id)); err != nil {
+		respondCredentialError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Credential deleted",
+	})
+}
+
+// loadCredential fetches the credential named by the :id parameter, writing
+// an error response and returning false if it cannot be found
+func loadCredential(c *gin.Context) (models.SiteCredential, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid ID format",
+		})
+		return models.SiteCredential{}, false
+	}
+
+	cred, err := services.GetCredential(uint(id))
+	if err != nil {
+		respondCredentialError(c, err)
+		return models.SiteCredential{}, false
+	}
+	return cred, true
+}
+
+// respondCredentialError maps credential service errors onto HTTP responses
+func respondCredentialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCredential):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrNoCredentialKey):
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "error",
+			"message": "Credential storage is disabled; set CREDENTIALS_KEY",
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Credential not found",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to save credential",
+			"error":   err.Error(),
+		})
+	}
+}
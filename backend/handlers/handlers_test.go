@@ -3,8 +3,11 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,7 +30,7 @@ func setupTestDB() {
 	}
 	
 	// Migrate the schema
-	db.DB.AutoMigrate(&models.ScrapedItem{})
+	db.DB.AutoMigrate(&models.ScrapedItem{}, &models.ScrapeJob{}, &models.Schedule{}, &models.PriceObservation{}, &models.ItemVersion{}, &models.FrontierURL{}, &models.SiteCredential{}, &models.PageValidator{})
 	
 	// Add some test data
 	testItems := []models.ScrapedItem{
@@ -63,8 +66,27 @@ func setupRouter() *gin.Engine {
 	// Setup routes
 	r.POST("/api/v1/scrape", StartScraping)
 	r.GET("/api/v1/scrape/status", GetScrapingStatus)
+	r.GET("/api/v1/scrape/jobs", ListScrapeJobs)
+	r.GET("/api/v1/scrape/jobs/:id", GetScrapeJob)
+	r.DELETE("/api/v1/scrape/jobs/:id", CancelScrapeJob)
+	r.POST("/api/v1/scrape/jobs/:id/resume", ResumeScrapeJob)
+	r.GET("/api/v1/scrape/jobs/:id/failed", ListFailedURLs)
+	r.POST("/api/v1/scrape/jobs/:id/retry-failed", RetryFailedScrapeJob)
+	r.GET("/api/v1/proxies", GetProxyStats)
+	r.GET("/api/v1/credentials", ListCredentials)
+	r.POST("/api/v1/credentials", CreateCredential)
+	r.GET("/api/v1/credentials/:id", GetCredential)
+	r.PUT("/api/v1/credentials/:id", UpdateCredential)
+	r.DELETE("/api/v1/credentials/:id", DeleteCredential)
+	r.GET("/api/v1/schedules", ListSchedules)
+	r.POST("/api/v1/schedules", CreateSchedule)
+	r.GET("/api/v1/schedules/:id", GetSchedule)
+	r.DELETE("/api/v1/schedules/:id", DeleteSchedule)
 	r.GET("/api/v1/data", GetScrapedData)
 	r.GET("/api/v1/data/:id", GetItemById)
+	r.GET("/api/v1/data/:id/prices", GetItemPrices)
+	r.GET("/api/v1/data/:id/versions", GetItemVersions)
+	r.GET("/api/v1/data/:id/diff", GetItemDiff)
 	
 	return r
 }
@@ -124,6 +146,7 @@ func TestStartScrapingWithURL(t *testing.T) {
 	assert.Equal(t, "success", response["status"])
 	assert.Equal(t, "Scraping started", response["message"])
 	assert.Equal(t, "https://example.com", response["url"])
+	assert.NotEmpty(t, response["job_id"])
 }
 
 func TestStartScrapingWithURLQueryParam(t *testing.T) {
@@ -270,4 +293,322 @@ func TestGetItemByIdWithInvalidId(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "error", response["status"])
 	assert.Equal(t, "Invalid ID format", response["message"])
-}
\ No newline at end of file
+}
+
+func TestGetScrapeJobAfterStart(t *testing.T) {
+	router := setupRouter()
+	
+	// Start a job so there is a record to look up
+	req, _ := http.NewRequest("POST", "/api/v1/scrape?url=https://example.com", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	
+	var started map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	jobID, _ := started["job_id"].(string)
+	assert.NotEmpty(t, jobID)
+	
+	// Fetch the job by ID
+	req, _ = http.NewRequest("GET", "/api/v1/scrape/jobs/"+jobID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, "success", response["status"])
+	
+	data, ok := response["data"].(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, jobID, data["id"])
+	assert.Equal(t, "https://example.com", data["target_url"])
+	
+	// The job should also show up in the history
+	req, _ = http.NewRequest("GET", "/api/v1/scrape/jobs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var list map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	assert.GreaterOrEqual(t, list["total"], float64(1))
+}
+
+func TestGetScrapeJobNotFound(t *testing.T) {
+	router := setupRouter()
+	
+	req, _ := http.NewRequest("GET", "/api/v1/scrape/jobs/does-not-exist", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListScrapeJobsWithInvalidState(t *testing.T) {
+	router := setupRouter()
+	
+	req, _ := http.NewRequest("GET", "/api/v1/scrape/jobs?state=bogus", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCancelScrapeJobNotFound(t *testing.T) {
+	router := setupRouter()
+	
+	req, _ := http.NewRequest("DELETE", "/api/v1/scrape/jobs/does-not-exist", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumeScrapeJobNotFound(t *testing.T) {
+	router := setupRouter()
+	
+	req, _ := http.NewRequest("POST", "/api/v1/scrape/jobs/does-not-exist/resume", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestResumeScrapeJobWithoutPendingURLs(t *testing.T) {
+	router := setupRouter()
+	
+	// A job from an earlier run whose frontier is empty
+	db.DB.Create(&models.ScrapeJob{ID: "nothing-pending", TargetURL: "https://example.com", Config: "{}", State: models.JobStateFailed})
+	
+	req, _ := http.NewRequest("POST", "/api/v1/scrape/jobs/nothing-pending/resume", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusConflict, w.Code)
+	
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "Job has no pending URLs to resume", response["message"])
+}
+
+func TestRetryFailedScrapeJobWithoutFailures(t *testing.T) {
+	router := setupRouter()
+	
+	db.DB.Create(&models.ScrapeJob{ID: "no-failures", TargetURL: "https://example.com", Config: "{}", State: models.JobStateSucceeded})
+	
+	req, _ := http.NewRequest("GET", "/api/v1/scrape/jobs/no-failures/failed", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	req, _ = http.NewRequest("POST", "/api/v1/scrape/jobs/no-failures/retry-failed", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	
+	req, _ = http.NewRequest("POST", "/api/v1/scrape/jobs/does-not-exist/retry-failed", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStartScrapingWithInvalidProxy(t *testing.T) {
+	router := setupRouter()
+	
+	body := map[string]interface{}{
+		"url":     "https://example.com",
+		"proxies": []string{"ftp://proxy.local:21"},
+	}
+	jsonBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", "/api/v1/scrape", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	
+	req, _ = http.NewRequest("GET", "/api/v1/proxies", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCredentialSecretsAreWriteOnly(t *testing.T) {
+	router := setupRouter()
+	
+	body := map[string]interface{}{
+		"name":         "partner-portal",
+		"login_url":    "https://partner.example.com/login",
+		"login_fields": map[string]string{"user": "ann", "pass": "hunter22"},
+		"auth":         map[string]string{"type": "bearer", "token": "tok-123456"},
+	}
+	jsonBody, _ := json.Marshal(body)
+	
+	// Without a key nothing can be stored
+	req, _ := http.NewRequest("POST", "/api/v1/credentials", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	
+	services.SetCredentialKey(strings.Repeat("ab", 32))
+	defer services.SetCredentialKey("")
+	
+	req, _ = http.NewRequest("POST", "/api/v1/credentials", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	var created struct {
+		Data models.SiteCredential `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	assert.NotZero(t, created.Data.ID)
+	
+	for _, path := range []string{"/api/v1/credentials", fmt.Sprintf("/api/v1/credentials/%d", created.Data.ID)} {
+		req, _ = http.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "partner-portal")
+		assert.NotContains(t, w.Body.String(), "hunter22")
+		assert.NotContains(t, w.Body.String(), "tok-123456")
+	}
+	
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/credentials/%d", created.Data.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCreateAndDeleteSchedule(t *testing.T) {
+	router := setupRouter()
+	
+	body := map[string]interface{}{
+		"url":       "https://example.com",
+		"max_depth": 1,
+		"cron_expr": "0 3 * * *",
+	}
+	jsonBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", "/api/v1/schedules", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusCreated, w.Code)
+	
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, true, data["enabled"])
+	assert.NotNil(t, data["next_run_at"])
+	
+	id := int(data["ID"].(float64))
+	path := "/api/v1/schedules/" + strconv.Itoa(id)
+	
+	req, _ = http.NewRequest("GET", path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	req, _ = http.NewRequest("DELETE", path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	req, _ = http.NewRequest("GET", path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateScheduleWithInvalidCron(t *testing.T) {
+	router := setupRouter()
+	
+	body := map[string]interface{}{
+		"url":       "https://example.com",
+		"cron_expr": "whenever",
+	}
+	jsonBody, _ := json.Marshal(body)
+	req, _ := http.NewRequest("POST", "/api/v1/schedules", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetItemPrices(t *testing.T) {
+	router := setupRouter()
+	
+	var item models.ScrapedItem
+	db.DB.Where("url = ?", "https://example.com/item1").First(&item)
+	
+	// Two crawls saw different prices
+	now := time.Now()
+	db.DB.Create(&models.PriceObservation{ItemID: item.ID, Price: 21.5, Currency: "USD", ObservedAt: now})
+	db.DB.Create(&models.PriceObservation{ItemID: item.ID, Price: 19.99, Currency: "USD", ObservedAt: now.Add(-24 * time.Hour)})
+	
+	req, _ := http.NewRequest("GET", "/api/v1/data/"+strconv.Itoa(int(item.ID))+"/prices", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data, _ := response["data"].([]interface{})
+	if assert.Len(t, data, 2) {
+		// Oldest first
+		assert.Equal(t, 19.99, data[0].(map[string]interface{})["price"])
+		assert.Equal(t, 21.5, data[1].(map[string]interface{})["price"])
+	}
+}
+
+func TestGetItemPricesNotFound(t *testing.T) {
+	router := setupRouter()
+	
+	req, _ := http.NewRequest("GET", "/api/v1/data/99999/prices", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetItemDiff(t *testing.T) {
+	router := setupRouter()
+	
+	var item models.ScrapedItem
+	db.DB.Where("url = ?", "https://example.com/item2").First(&item)
+	db.DB.Create(&models.ItemVersion{ItemID: item.ID, Version: 1, Title: "Test Item 2", Price: 29.99, Metadata: `{"category": "Another Category"}`})
+	db.DB.Create(&models.ItemVersion{ItemID: item.ID, Version: 2, Title: "Test Item 2", Price: 24.99, Metadata: `{"category": "Sale"}`})
+	
+	base := "/api/v1/data/" + strconv.Itoa(int(item.ID))
+	
+	req, _ := http.NewRequest("GET", base+"/versions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	req, _ = http.NewRequest("GET", base+"/diff?from=1&to=2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	data, _ := response["data"].(map[string]interface{})
+	changes, _ := data["changes"].([]interface{})
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, "price", changes[0].(map[string]interface{})["field"])
+		assert.Equal(t, "metadata.category", changes[1].(map[string]interface{})["field"])
+	}
+	
+	req, _ = http.NewRequest("GET", base+"/diff?from=1&to=9", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
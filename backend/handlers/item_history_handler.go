@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/arkouda/scrape-n-serve/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetItemPrices handles the request for an item's price history. The optional
+// since and until query parameters take RFC 3339 timestamps.
+func GetItemPrices(c *gin.Context) {
+	item, ok := loadItem(c)
+	if !ok {
+		return
+	}
+
+	var since, until time.Time
+	for name, target := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid " + name + " timestamp, expected RFC 3339",
+			})
+			return
+		}
+		*target = parsed
+	}
+
+	observations, err := services.GetPriceHistory(item.ID, since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve price history",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"item_id": item.ID,
+		"count":   len(observations),
+		"data":    observations,
+	})
+}
+
+// GetItemVersions handles the request for an item's stored versions, oldest first
+func GetItemVersions(c *gin.Context) {
+	item, ok := loadItem(c)
+	if !ok {
+		return
+	}
+
+	versions, err := services.ListItemVersions(item.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve item versions",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"item_id": item.ID,
+		"count":   len(versions),
+		"data":    versions,
+	})
+}
+
+// GetItemDiff handles the request for the field-level differences between two
+// versions of an item. to defaults to the latest version and from to the one before it.
+func GetItemDiff(c *gin.Context) {
+	item, ok := loadItem(c)
+	if !ok {
+		return
+	}
+
+	var from, to int
+	for name, target := range map[string]*int{"from": &from, "to": &to} {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		version, err := strconv.Atoi(value)
+		if err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Invalid " + name + " version",
+			})
+			return
+		}
+		*target = version
+	}
+
+	diff, err := services.DiffItemVersions(item.ID, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "Item version not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to compare item versions",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   diff,
+	})
+}
+
+// loadItem looks up the item named by the :id path parameter, writing an
+// error response and returning false if it cannot be found
+func loadItem(c *gin.Context) (models.ScrapedItem, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid ID format",
+		})
+		return models.ScrapedItem{}, false
+	}
+
+	var item models.ScrapedItem
+	if err := db.DB.First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "Item not found",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Failed to retrieve item",
+				"error":   err.Error(),
+			})
+		}
+		return models.ScrapedItem{}, false
+	}
+	return item, true
+}
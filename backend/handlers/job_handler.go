@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/arkouda/scrape-n-serve/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validJobStates lists the states accepted by the job history filter
+var validJobStates = map[string]bool{
+	models.JobStateQueued:    true,
+	models.JobStateRunning:   true,
+	models.JobStateSucceeded: true,
+	models.JobStateFailed:    true,
+	models.JobStateCancelled: true,
+}
+
+// ListScrapeJobs handles the request to list scraping job history
+func ListScrapeJobs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	state := c.Query("state")
+	if state != "" && !validJobStates[state] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid job state",
+		})
+		return
+	}
+
+	jobs, totalCount, err := services.ListJobs(limit, offset, state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve jobs",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  len(jobs),
+		"total":  totalCount,
+		"limit":  limit,
+		"offset": offset,
+		"data":   jobs,
+	})
+}
+
+// GetScrapeJob handles the request to get a single scraping job by ID
+func GetScrapeJob(c *gin.Context) {
+	job, err := services.GetJob(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "Job not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve job",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// CancelScrapeJob handles the request to cancel a queued or running scraping job.
+// Items already saved by the job are kept.
+func CancelScrapeJob(c *gin.Context) {
+	id := c.Param("id")
+
+	err := services.CancelJob(id)
+	if errors.Is(err, services.ErrJobNotFound) {
+		// Jobs from earlier runs only exist in the database and are already finished
+		if _, lookupErr := services.GetJob(id); lookupErr == nil {
+			err = services.ErrJobNotActive
+		}
+	}
+
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Job not found",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Job is not queued or running",
+		})
+		return
+	}
+
+	logger.Info("Cancellation requested for job %s", id)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "Cancellation requested",
+		"job_id":  id,
+	})
+}
+
+// ResumeScrapeJob handles the request to continue an interrupted or cancelled
+// scraping job from its persisted frontier
+func ResumeScrapeJob(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := services.ResumeJob(id); err != nil {
+		respondJobRestartError(c, err, "Failed to resume job")
+		return
+	}
+
+	logger.Info("Resuming job %s", id)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "Job resumed",
+		"job_id":  id,
+	})
+}
+
+// RetryFailedScrapeJob handles the request to fetch the pages of a finished
+// job that failed on their last attempt again
+func RetryFailedScrapeJob(c *gin.Context) {
+	id := c.Param("id")
+
+	requeued, err := services.RetryFailedURLs(id)
+	if err != nil {
+		respondJobRestartError(c, err, "Failed to retry failed URLs")
+		return
+	}
+
+	logger.Info("Retrying %d failed URLs of job %s", requeued, id)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":   "success",
+		"message":  "Failed URLs requeued",
+		"job_id":   id,
+		"requeued": requeued,
+	})
+}
+
+// ListFailedURLs handles the request to list the pages of a job that still
+// failed after their last attempt
+func ListFailedURLs(c *gin.Context) {
+	urls, err := services.GetFailedURLs(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "Job not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve failed URLs",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  len(urls),
+		"data":   urls,
+	})
+}
+
+// respondJobRestartError maps errors from resuming or retrying a job to a response
+func respondJobRestartError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Job not found",
+		})
+	case errors.Is(err, services.ErrJobActive):
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Job is already queued or running",
+		})
+	case errors.Is(err, services.ErrNothingToResume):
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Job has no pending URLs to resume",
+		})
+	case errors.Is(err, services.ErrNoFailedURLs):
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "Job has no failed URLs",
+		})
+	case errors.Is(err, services.ErrSecretsNotStored):
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": message,
+			"error":   err.Error(),
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/arkouda/scrape-n-serve/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetProxyStats handles the request to report the usage and health of every proxy
+func GetProxyStats(c *gin.Context) {
+	stats := services.GetProxyStats()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  len(stats),
+		"data":   stats,
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/arkouda/scrape-n-serve/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ScheduleRequest represents the expected request body for creating or updating a schedule
+type ScheduleRequest struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	MaxDepth        int    `json:"max_depth"`
+	Upsert          bool   `json:"upsert"`
+	CredentialID    uint   `json:"credential_id"`
+	CronExpr        string `json:"cron_expr"`
+	IntervalMinutes int    `json:"interval_minutes"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// apply copies the request fields onto a schedule
+func (r ScheduleRequest) apply(schedule *models.Schedule) {
+	schedule.Name = r.Name
+	schedule.URL = r.URL
+	schedule.MaxDepth = r.MaxDepth
+	schedule.Upsert = r.Upsert
+	schedule.CredentialID = r.CredentialID
+	schedule.CronExpr = r.CronExpr
+	schedule.IntervalMinutes = r.IntervalMinutes
+	schedule.Enabled = r.Enabled == nil || *r.Enabled
+}
+
+// ListSchedules handles the request to list all schedules
+func ListSchedules(c *gin.Context) {
+	schedules, err := services.ListSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to retrieve schedules",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"count":  len(schedules),
+		"data":   schedules,
+	})
+}
+
+// CreateSchedule handles the request to create a recurring crawl
+func CreateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+		return
+	}
+
+	var schedule models.Schedule
+	req.apply(&schedule)
+
+	if err := services.CreateSchedule(&schedule); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   schedule,
+	})
+}
+
+// GetSchedule handles the request to get a schedule by ID
+func GetSchedule(c *gin.Context) {
+	schedule, ok := loadSchedule(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   schedule,
+	})
+}
+
+// UpdateSchedule handles the request to replace a schedule's settings
+func UpdateSchedule(c *gin.Context) {
+	schedule, ok := loadSchedule(c)
+	if !ok {
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+		return
+	}
+	req.apply(&schedule)
+
+	if err := services.UpdateSchedule(&schedule); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   schedule,
+	})
+}
+
+// DeleteSchedule handles the request to remove a schedule
+func DeleteSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid ID format",
+		})
+		return
+	}
+
+	if err := services.DeleteSchedule(uint(id)); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Schedule deleted",
+	})
+}
+
+// loadSchedule fetches the schedule named by the :id parameter, writing an
+// error response and returning false if it cannot be found
+func loadSchedule(c *gin.Context) (models.Schedule, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "Invalid ID format",
+		})
+		return models.Schedule{}, false
+	}
+
+	schedule, err := services.GetSchedule(uint(id))
+	if err != nil {
+		respondScheduleError(c, err)
+		return models.Schedule{}, false
+	}
+	return schedule, true
+}
+
+// respondScheduleError maps schedule service errors onto HTTP responses
+func respondScheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "Schedule not found",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "Failed to save schedule",
+			"error":   err.Error(),
+		})
+	}
+}
@@ -18,8 +18,28 @@ var (
 
 // ScrapingRequest represents the expected request body for scraping
 type ScrapingRequest struct {
-	URL     string `json:"url" binding:"required"`
-	MaxDepth int    `json:"max_depth"`
+	URL                string                  `json:"url" binding:"required"`
+	MaxDepth           int                     `json:"max_depth"`
+	IgnoreRobotsTxt    bool                    `json:"ignore_robots_txt"`
+	UseSitemaps        bool                    `json:"use_sitemaps"`
+	SitemapSince       *time.Time              `json:"sitemap_since"`
+	Upsert             bool                    `json:"upsert"`
+	Proxies            []string                `json:"proxies"`
+	ProxySelection     string                  `json:"proxy_selection"`
+	Headers            map[string]string       `json:"headers"`
+	Cookies            []services.CookieOption `json:"cookies"`
+	Auth               *services.AuthOptions   `json:"auth"`
+	Login              *services.LoginOptions  `json:"login"`
+	CredentialID       uint                    `json:"credential_id"` // Stored credential; its secrets never appear in the job record
+	Scope              string                  `json:"scope"`
+	AllowedHosts       []string                `json:"allowed_hosts"`
+	Include            []string                `json:"include"`
+	Exclude            []string                `json:"exclude"`
+	MaxPages           int                     `json:"max_pages"`
+	MaxItems           int                     `json:"max_items"`
+	MaxBytes           int64                   `json:"max_bytes"`
+	MaxDurationSeconds int                     `json:"max_duration_seconds"`
+	FullRecrawl        bool                    `json:"full_recrawl"`
 }
 
 // StartScraping handles the request to start the scraping process
@@ -46,40 +66,60 @@ func StartScraping(c *gin.Context) {
 		return
 	}
 
-	// Check if scraping is already in progress
-	if services.IsScrapingInProgress() {
-		c.JSON(http.StatusConflict, gin.H{
+	// Set max depth if provided, otherwise use default
+	maxDepth := 2 // Default value
+	if req.MaxDepth > 0 {
+		maxDepth = req.MaxDepth
+	}
+
+	// Submit the job; the crawl itself runs in the background
+	jobID, err := services.StartScraping(services.ScrapeOptions{
+		URL:                req.URL,
+		MaxDepth:           maxDepth,
+		IgnoreRobotsTxt:    req.IgnoreRobotsTxt,
+		UseSitemaps:        req.UseSitemaps,
+		SitemapSince:       req.SitemapSince,
+		Upsert:             req.Upsert,
+		Proxies:            req.Proxies,
+		ProxySelection:     req.ProxySelection,
+		Headers:            req.Headers,
+		Cookies:            req.Cookies,
+		Auth:               req.Auth,
+		Login:              req.Login,
+		CredentialID:       req.CredentialID,
+		Scope:              req.Scope,
+		AllowedHosts:       req.AllowedHosts,
+		Include:            req.Include,
+		Exclude:            req.Exclude,
+		MaxPages:           req.MaxPages,
+		MaxItems:           req.MaxItems,
+		MaxBytes:           req.MaxBytes,
+		MaxDurationSeconds: req.MaxDurationSeconds,
+		FullRecrawl:        req.FullRecrawl,
+	})
+	if err != nil {
+		logger.Error("Error starting scraping: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
-			"message": "Scraping is already in progress",
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// Start scraping in a goroutine
-	go func() {
-		// Set max depth if provided, otherwise use default
-		maxDepth := 2 // Default value
-		if req.MaxDepth > 0 {
-			maxDepth = req.MaxDepth
-		}
-		
-		_, err := services.StartScraping(req.URL, maxDepth)
-		if err != nil {
-			logger.Error("Error during scraping: %v", err)
-		}
-	}()
-
 	c.JSON(http.StatusAccepted, gin.H{
 		"status":  "success",
 		"message": "Scraping started",
+		"job_id":  jobID,
 		"url":     req.URL,
 		"time":    time.Now(),
 	})
 }
 
-// GetScrapingStatus checks if a scraping job is currently running
+// GetScrapingStatus checks if any scraping job is currently running
 func GetScrapingStatus(c *gin.Context) {
 	isRunning := services.IsScrapingInProgress()
+	runningJobs := services.RunningJobCount()
+	activeJobs := services.GetActiveJobs()
 	
 	status := "idle"
 	if isRunning {
@@ -87,10 +127,12 @@ func GetScrapingStatus(c *gin.Context) {
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
-		"status":      "success",
-		"scraping":    isRunning,
-		"state":       status,
-		"time":        time.Now(),
+		"status":       "success",
+		"scraping":     isRunning,
+		"state":        status,
+		"running_jobs": runningJobs,
+		"jobs":         activeJobs,
+		"time":         time.Now(),
 	})
 }
 
@@ -2,10 +2,12 @@ package main
 
 import (
 	"log"
+	"os"
 	"time"
 	
 	"github.com/arkouda/scrape-n-serve/db"
 	"github.com/arkouda/scrape-n-serve/handlers"
+	"github.com/arkouda/scrape-n-serve/services"
 	"github.com/arkouda/scrape-n-serve/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,6 +16,7 @@ import (
 func main() {
 	// Initialize logger
 	logger := utils.NewLogger("main")
+	cfg := GetConfig()
 	
 	// Initialize database
 	if err := db.Connect(); err != nil {
@@ -22,6 +25,44 @@ func main() {
 	}
 	logger.Info("Connected to database successfully")
 	
+	// Jobs left active by a previous run can never finish
+	if err := services.MarkInterruptedJobs(); err != nil {
+		logger.Error("Failed to mark interrupted jobs: %v", err)
+	}
+	
+	// Load per-site extraction profiles
+	count, err := services.LoadExtractionProfiles(cfg.ProfilesDir)
+	if err != nil {
+		logger.Error("Failed to load extraction profiles: %v", err)
+		log.Fatalf("Failed to load extraction profiles: %v", err)
+	}
+	logger.Info("Loaded %d extraction profiles from %s", count, cfg.ProfilesDir)
+	
+	// Proxies used by jobs that do not list their own
+	if err := services.SetDefaultProxies(cfg.ProxyURLs, cfg.ProxySelection); err != nil {
+		logger.Error("Invalid proxy settings: %v", err)
+		log.Fatalf("Invalid proxy settings: %v", err)
+	}
+	
+	// Site credentials are stored encrypted and kept out of the logs
+	log.SetOutput(utils.NewRedactingWriter(os.Stderr))
+	if err := services.SetCredentialKey(cfg.CredentialKey); err != nil {
+		logger.Error("Invalid credential key: %v", err)
+		log.Fatalf("Invalid credential key: %v", err)
+	}
+	if cfg.CredentialKey == "" {
+		logger.Info("CREDENTIALS_KEY is not set; stored site credentials are disabled")
+	}
+	
+	// Query parameters kept when URLs are canonicalized
+	services.SetURLParamRules(cfg.URLAllowParams, cfg.URLDenyParams)
+	
+	// Cap the number of scraping jobs running at once
+	services.SetMaxConcurrentJobs(cfg.MaxConcurrentJobs)
+	
+	// Fire recurring crawls; ScrapingPeriod is the default interval
+	services.StartScheduler(time.Duration(cfg.ScrapingPeriod) * time.Minute)
+	
 	// Set Gin to release mode in production
 	gin.SetMode(gin.ReleaseMode)
 	
@@ -59,12 +100,37 @@ func main() {
 		// Scraping endpoints
 		v1.POST("/scrape", handlers.StartScraping)
 		v1.GET("/scrape/status", handlers.GetScrapingStatus)
+		v1.GET("/scrape/jobs", handlers.ListScrapeJobs)
+		v1.GET("/scrape/jobs/:id", handlers.GetScrapeJob)
+		v1.DELETE("/scrape/jobs/:id", handlers.CancelScrapeJob)
+		v1.POST("/scrape/jobs/:id/cancel", handlers.CancelScrapeJob)
+		v1.POST("/scrape/jobs/:id/resume", handlers.ResumeScrapeJob)
+		v1.GET("/scrape/jobs/:id/failed", handlers.ListFailedURLs)
+		v1.POST("/scrape/jobs/:id/retry-failed", handlers.RetryFailedScrapeJob)
+		v1.GET("/proxies", handlers.GetProxyStats)
+		
+		// Site credential endpoints; secrets are write-only
+		v1.GET("/credentials", handlers.ListCredentials)
+		v1.POST("/credentials", handlers.CreateCredential)
+		v1.GET("/credentials/:id", handlers.GetCredential)
+		v1.PUT("/credentials/:id", handlers.UpdateCredential)
+		v1.DELETE("/credentials/:id", handlers.DeleteCredential)
+		
+		// Schedule endpoints
+		v1.GET("/schedules", handlers.ListSchedules)
+		v1.POST("/schedules", handlers.CreateSchedule)
+		v1.GET("/schedules/:id", handlers.GetSchedule)
+		v1.PUT("/schedules/:id", handlers.UpdateSchedule)
+		v1.DELETE("/schedules/:id", handlers.DeleteSchedule)
 		
 		// Data endpoints
 		v1.GET("/data", handlers.GetScrapedData)
 		v1.GET("/data/search", handlers.SearchData)
 		v1.GET("/data/stats", handlers.GetStats)
 		v1.GET("/data/:id", handlers.GetItemById)
+		v1.GET("/data/:id/prices", handlers.GetItemPrices)
+		v1.GET("/data/:id/versions", handlers.GetItemVersions)
+		v1.GET("/data/:id/diff", handlers.GetItemDiff)
 	}
 	
 	// Health check endpoint
@@ -9,11 +9,17 @@ import (
 // ScrapedItem represents data scraped from the target website
 type ScrapedItem struct {
 	gorm.Model
-	Title       string    `json:"title" gorm:"index"`
-	Description string    `json:"description"`
-	URL         string    `json:"url" gorm:"uniqueIndex"`
-	ImageURL    string    `json:"image_url"`
-	Price       float64   `json:"price"`
-	ScrapedAt   time.Time `json:"scraped_at" gorm:"index"`
-	Metadata    string    `json:"metadata" gorm:"type:jsonb"`
+	Title         string    `json:"title" gorm:"index"`
+	Description   string    `json:"description"`
+	URL           string    `json:"url" gorm:"uniqueIndex"` // Canonical URL the item is keyed by
+	OriginalURL   string    `json:"original_url"`           // URL the item was first extracted from
+	ImageURL      string    `json:"image_url"`
+	Price         float64   `json:"price"`
+	Currency      string    `json:"currency"`   // ISO 4217 code of Price
+	PriceText     string    `json:"price_text"` // Price as written on the page
+	ScrapedAt     time.Time `json:"scraped_at" gorm:"index"`
+	Metadata      string    `json:"metadata" gorm:"type:jsonb"`
+	ContentHash   string    `json:"content_hash" gorm:"size:64"` // Hash of the normalized extracted fields
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	LastChangedAt time.Time `json:"last_changed_at" gorm:"index"`
 }
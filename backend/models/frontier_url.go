@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Frontier URL states
+const (
+	FrontierPending = "pending" // Queued but not fetched yet
+	FrontierDone    = "done"    // Fetched and extracted
+	FrontierFailed  = "failed"  // Fetch failed
+	FrontierSkipped = "skipped" // Not fetched, e.g. disallowed by robots.txt
+)
+
+// FrontierURL is a URL queued by a scrape job. Together the rows of a job
+// form its crawl frontier and visited set, so an interrupted crawl can resume.
+type FrontierURL struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	JobID      string    `json:"job_id" gorm:"size:32;uniqueIndex:idx_frontier_urls_job_url"`
+	URL        string    `json:"url" gorm:"uniqueIndex:idx_frontier_urls_job_url"` // Canonical URL
+	FetchURL   string    `json:"fetch_url"`                                        // URL as requested
+	Depth      int       `json:"depth"`
+	State      string    `json:"state" gorm:"size:16;index"`
+	Attempts   int       `json:"attempts"`    // Fetches made before the URL failed
+	StatusCode int       `json:"status_code"` // Last HTTP status, 0 for network errors
+	LastError  string    `json:"last_error"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
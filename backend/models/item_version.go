@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ItemVersion is a snapshot of a ScrapedItem's content, taken when the item
+// is first stored and each time a crawl finds it changed
+type ItemVersion struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ItemID      uint      `json:"item_id" gorm:"uniqueIndex:idx_item_versions_item_version"`
+	Version     int       `json:"version" gorm:"uniqueIndex:idx_item_versions_item_version"`
+	JobID       string    `json:"job_id" gorm:"size:32"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	ImageURL    string    `json:"image_url"`
+	Price       float64   `json:"price"`
+	Currency    string    `json:"currency"`
+	PriceText   string    `json:"price_text"`
+	Metadata    string    `json:"metadata" gorm:"type:jsonb"`
+	ContentHash string    `json:"content_hash" gorm:"size:64"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PageValidator keeps the cache validators of a page from its last full
+// fetch. Later crawls send them back as conditional GET headers, and replay
+// the page's links and items when the server answers 304 Not Modified.
+type PageValidator struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	URL          string    `json:"url" gorm:"uniqueIndex"` // Canonical URL
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	Size         int64     `json:"size"`                        // Body bytes of the last full fetch
+	Links        string    `json:"links" gorm:"type:jsonb"`     // Links found on the page
+	ItemURLs     string    `json:"item_urls" gorm:"type:jsonb"` // Items extracted from the page
+	UpdatedAt    time.Time `json:"updated_at"`
+}
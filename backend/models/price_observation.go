@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PriceObservation records an item's price and availability as seen by one crawl
+type PriceObservation struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ItemID       uint      `json:"item_id" gorm:"index:idx_price_observations_item_time"`
+	JobID        string    `json:"job_id" gorm:"size:32"`
+	Price        float64   `json:"price"`
+	Currency     string    `json:"currency"`
+	Availability string    `json:"availability"`
+	ObservedAt   time.Time `json:"observed_at" gorm:"index:idx_price_observations_item_time"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schedule describes a recurring crawl. Exactly one of CronExpr or
+// IntervalMinutes is used; when neither is set the global scraping period applies.
+type Schedule struct {
+	gorm.Model
+	Name            string     `json:"name"`
+	URL             string     `json:"url"`
+	MaxDepth        int        `json:"max_depth"`
+	Upsert          bool       `json:"upsert"`        // Update changed items on each run
+	CredentialID    uint       `json:"credential_id"` // Stored credential to log in with, 0 for none
+	CronExpr        string     `json:"cron_expr"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	Enabled         bool       `json:"enabled" gorm:"index"`
+	LastRunAt       *time.Time `json:"last_run_at"`
+	NextRunAt       *time.Time `json:"next_run_at" gorm:"index"`
+	LastJobID       string     `json:"last_job_id"`
+	SkippedRuns     int        `json:"skipped_runs"`
+}
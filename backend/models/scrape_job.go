@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Scrape job states
+const (
+	JobStateQueued    = "queued"
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+// Budget limits that can stop a job, recorded in ScrapeJob.StopReason
+const (
+	StopMaxPages    = "max_pages"
+	StopMaxItems    = "max_items"
+	StopMaxBytes    = "max_bytes"
+	StopMaxDuration = "max_duration"
+)
+
+// ScrapeJob records a single crawl and its outcome
+type ScrapeJob struct {
+	ID                 string     `json:"id" gorm:"primaryKey;size:32"`
+	TargetURL          string     `json:"target_url"`
+	Config             string     `json:"config" gorm:"type:jsonb"`
+	State              string     `json:"state" gorm:"index"`
+	CreatedAt          time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	StartedAt          *time.Time `json:"started_at"`
+	FinishedAt         *time.Time `json:"finished_at"`
+	PagesVisited       int        `json:"pages_visited"`
+	ItemsSaved         int        `json:"items_saved"` // New items
+	ItemsUpdated       int        `json:"items_updated"`
+	ItemsUnchanged     int        `json:"items_unchanged"`
+	ErrorCount         int        `json:"error_count"`
+	RobotsSkipped      int        `json:"robots_skipped"`
+	SitemapURLs        int        `json:"sitemap_urls"`
+	ResumeCount        int        `json:"resume_count"`        // Times the job was resumed or had its failed URLs retried
+	Retries            int        `json:"retries"`             // Failed fetches that were tried again
+	FailedURLs         int        `json:"failed_urls"`         // Pages that still failed after their last attempt
+	ThrottledResponses int        `json:"throttled_responses"` // 429 and 503 responses received
+	MaxBackoffSeconds  float64    `json:"max_backoff_seconds"` // Longest pause applied to a throttling host
+	BytesFetched       int64      `json:"bytes_fetched"`       // Response bodies received
+	NotModified        int        `json:"not_modified"`        // Pages answered 304 instead of being fetched in full
+	BytesSaved         int64      `json:"bytes_saved"`         // Body bytes the 304 answers did not transfer, going by their last full fetch
+	StopReason         string     `json:"stop_reason"`         // Budget limit that ended the last run, empty if none did
+	LastError          string     `json:"last_error"`
+}
+
+// IsActive reports whether the job is still queued or running
+func (j ScrapeJob) IsActive() bool {
+	return j.State == JobStateQueued || j.State == JobStateRunning
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SiteCredential holds the secrets needed to crawl a site behind a login.
+// The login settings are stored as is; usernames, passwords, tokens, headers
+// and cookies are kept encrypted in Secret, which is never serialized.
+type SiteCredential struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Name          string    `json:"name" gorm:"uniqueIndex"`
+	Domain        string    `json:"domain"`         // Site the credential is for, informational
+	LoginURL      string    `json:"login_url"`      // Login form submitted before crawling, if any
+	FormSelector  string    `json:"form_selector"`  // Picks the login form
+	SuccessText   string    `json:"success_text"`   // Must appear after logging in
+	SuccessCookie string    `json:"success_cookie"` // Must be set after logging in
+	Secret        string    `json:"-"`              // Encrypted secrets, base64
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryAfter caps how long a Retry-After header can pause a host
+const maxRetryAfter = 10 * time.Minute
+
+// hostThrottle adds an adaptive delay between requests to each host on top
+// of the collector's fixed limits. Throttling responses (429, 503) double a
+// host's delay, or pause it for Retry-After; each success shrinks the delay
+// by a quarter until it is gone. A host's Crawl-delay is kept as a floor
+// under the adaptive delay.
+type hostThrottle struct {
+	initial time.Duration // Delay after the first throttling response
+	max     time.Duration // Upper bound for the exponential delay
+
+	mu    sync.Mutex
+	hosts map[string]*hostBackoff
+	now   func() time.Time
+}
+
+// hostBackoff is the adaptive state of one host
+type hostBackoff struct {
+	delay time.Duration // Adaptive spacing added by throttling responses
+	floor time.Duration // Minimum spacing asked for by the host, e.g. Crawl-delay
+	next  time.Time     // Earliest time the next request may start
+}
+
+// spacing returns the delay enforced between requests to the host
+func (h *hostBackoff) spacing() time.Duration {
+	if h.floor > h.delay {
+		return h.floor
+	}
+	return h.delay
+}
+
+// newHostThrottle creates a throttle that starts backing off at initial and
+// never spaces requests more than max apart, apart from Retry-After pauses
+func newHostThrottle(initial, max time.Duration) *hostThrottle {
+	return &hostThrottle{
+		initial: initial,
+		max:     max,
+		hosts:   make(map[string]*hostBackoff),
+		now:     time.Now,
+	}
+}
+
+// isThrottlingStatus reports whether a response status asks us to slow down
+func isThrottlingStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// reserve claims the next request slot for host and returns how long the
+// caller has to wait for it
+func (t *hostThrottle) reserve(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hosts[host]
+	if !ok {
+		return 0
+	}
+
+	now := t.now()
+	start := now
+	if h.next.After(start) {
+		start = h.next
+	}
+	h.next = start.Add(h.spacing())
+	return start.Sub(now)
+}
+
+// wait blocks until host may be requested again. It returns early when done
+// is closed.
+func (t *hostThrottle) wait(host string, done <-chan struct{}) time.Duration {
+	delay := t.reserve(host)
+	if delay <= 0 {
+		return 0
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-done:
+	}
+	return delay
+}
+
+// throttled records a throttling response from host and returns the pause
+// now applied to it. retryAfter is the raw Retry-After header, if any.
+func (t *hostThrottle) throttled(host, retryAfter string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hosts[host]
+	if !ok {
+		h = &hostBackoff{}
+		t.hosts[host] = h
+	}
+
+	h.delay *= 2
+	if h.delay < t.initial {
+		h.delay = t.initial
+	}
+	if h.delay > t.max {
+		h.delay = t.max
+	}
+
+	now := t.now()
+	pause := h.delay
+	if wait, ok := parseRetryAfter(retryAfter, now); ok {
+		pause = wait
+	}
+	if next := now.Add(pause); next.After(h.next) {
+		h.next = next
+	}
+	return pause
+}
+
+// setFloor sets the minimum spacing between requests to host, whatever
+// its adaptive delay. A zero floor removes it.
+func (t *hostThrottle) setFloor(host string, floor time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hosts[host]
+	if !ok {
+		if floor <= 0 {
+			return
+		}
+		h = &hostBackoff{}
+		t.hosts[host] = h
+	}
+	h.floor = floor
+	if h.floor <= 0 && h.delay == 0 {
+		delete(t.hosts, host)
+	}
+}
+
+// succeeded eases off a host's delay after a successful response
+func (t *hostThrottle) succeeded(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hosts[host]
+	if !ok {
+		return
+	}
+
+	h.delay -= h.delay / 4
+	if h.delay < 100*time.Millisecond {
+		h.delay = 0
+		if h.floor == 0 {
+			delete(t.hosts, host)
+		}
+	}
+}
+
+// delay returns the adaptive delay currently added for host
+func (t *hostThrottle) delay(host string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if h, ok := t.hosts[host]; ok {
+		return h.delay
+	}
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date. The wait is capped at maxRetryAfter.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = at.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait, true
+}
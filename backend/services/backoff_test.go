@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostThrottleBacksOffAndRecovers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newHostThrottle(2*time.Second, 10*time.Second)
+	throttle.now = func() time.Time { return now }
+
+	if wait := throttle.reserve("shop.example"); wait != 0 {
+		t.Fatalf("Expected no wait before any throttling, got %v", wait)
+	}
+
+	// Each throttling response doubles the delay up to the cap
+	for _, want := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if pause := throttle.throttled("shop.example", ""); pause != want {
+			t.Errorf("Expected a pause of %v, got %v", want, pause)
+		}
+	}
+
+	// Requests are spaced by the current delay
+	if wait := throttle.reserve("shop.example"); wait != 10*time.Second {
+		t.Errorf("Expected the first request to wait 10s, got %v", wait)
+	}
+	if wait := throttle.reserve("shop.example"); wait != 20*time.Second {
+		t.Errorf("Expected the second request to wait 20s, got %v", wait)
+	}
+
+	// Other hosts are unaffected
+	if wait := throttle.reserve("blog.example"); wait != 0 {
+		t.Errorf("Expected other hosts not to wait, got %v", wait)
+	}
+
+	// Successes shrink the delay until the host is back to normal
+	throttle.succeeded("shop.example")
+	if delay := throttle.delay("shop.example"); delay != 7500*time.Millisecond {
+		t.Errorf("Expected the delay to shrink to 7.5s, got %v", delay)
+	}
+	for i := 0; i < 20; i++ {
+		throttle.succeeded("shop.example")
+	}
+	if delay := throttle.delay("shop.example"); delay != 0 {
+		t.Errorf("Expected the delay to be gone, got %v", delay)
+	}
+}
+
+func TestHostThrottleHonorsRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newHostThrottle(2*time.Second, 10*time.Second)
+	throttle.now = func() time.Time { return now }
+
+	if pause := throttle.throttled("shop.example", "30"); pause != 30*time.Second {
+		t.Errorf("Expected Retry-After to pause the host for 30s, got %v", pause)
+	}
+	if wait := throttle.reserve("shop.example"); wait != 30*time.Second {
+		t.Errorf("Expected the next request to wait 30s, got %v", wait)
+	}
+}
+
+func TestHostThrottleKeepsCrawlDelay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newHostThrottle(2*time.Second, 10*time.Second)
+	throttle.now = func() time.Time { return now }
+
+	throttle.setFloor("shop.example", 5*time.Second)
+	throttle.reserve("shop.example")
+	if wait := throttle.reserve("shop.example"); wait != 5*time.Second {
+		t.Errorf("Expected requests to be spaced by the crawl delay, got %v", wait)
+	}
+
+	// A longer backoff takes over, and the crawl delay outlasts its recovery
+	throttle.throttled("shop.example", "")
+	throttle.throttled("shop.example", "")
+	throttle.throttled("shop.example", "")
+	throttle.reserve("shop.example")
+	if wait := throttle.reserve("shop.example"); wait != 18*time.Second {
+		t.Errorf("Expected requests to be spaced by the 8s backoff, got %v", wait)
+	}
+	for i := 0; i < 20; i++ {
+		throttle.succeeded("shop.example")
+	}
+	now = now.Add(time.Minute)
+	throttle.reserve("shop.example")
+	if wait := throttle.reserve("shop.example"); wait != 5*time.Second {
+		t.Errorf("Expected the crawl delay to remain after recovering, got %v", wait)
+	}
+
+	throttle.setFloor("shop.example", 0)
+	now = now.Add(time.Minute)
+	if wait := throttle.reserve("shop.example"); wait != 0 {
+		t.Errorf("Expected no wait once the crawl delay is removed, got %v", wait)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Mon, 01 Jan 2024 12:00:45 GMT", 45 * time.Second, true},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0, true},
+		{"86400", maxRetryAfter, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+// crawlBudget bounds a single run of a job by pages fetched, items saved,
+// response bytes and wall-clock time. The first limit hit stops the run;
+// in-flight requests still finish, but waits end early. A nil budget never
+// runs out.
+type crawlBudget struct {
+	maxPages int
+	maxItems int
+	maxBytes int64
+	deadline time.Time // Zero without a duration limit
+	onStop   func(reason string)
+	now      func() time.Time
+	timer    *time.Timer   // Stops the run at the deadline
+	stopped  chan struct{} // Closed when the run is stopped
+
+	mu     sync.Mutex
+	pages  int
+	items  int
+	bytes  int64
+	reason string // Limit that stopped the run, one of the models.Stop* reasons
+}
+
+// validateBudget checks a job's budget options
+func validateBudget(opts ScrapeOptions) error {
+	if opts.MaxPages < 0 || opts.MaxItems < 0 || opts.MaxBytes < 0 || opts.MaxDurationSeconds < 0 {
+		return errors.New("invalid budget: limits must not be negative")
+	}
+	return nil
+}
+
+// newCrawlBudget creates the budget for a run starting at start. onStop is
+// called once, with the reason, when the run is stopped. Returns nil if the
+// job sets no limits. Callers must release the budget when the run ends.
+func newCrawlBudget(opts ScrapeOptions, start time.Time, onStop func(reason string)) *crawlBudget {
+	if opts.MaxPages == 0 && opts.MaxItems == 0 && opts.MaxBytes == 0 && opts.MaxDurationSeconds == 0 {
+		return nil
+	}
+
+	b := &crawlBudget{
+		maxPages: opts.MaxPages,
+		maxItems: opts.MaxItems,
+		maxBytes: opts.MaxBytes,
+		onStop:   onStop,
+		now:      time.Now,
+		stopped:  make(chan struct{}),
+	}
+	if opts.MaxDurationSeconds > 0 {
+		b.deadline = start.Add(time.Duration(opts.MaxDurationSeconds) * time.Second)
+		b.timer = time.AfterFunc(time.Until(b.deadline), func() { b.exhausted() })
+	}
+	return b
+}
+
+// done returns a channel closed when the run is stopped, so waits can end
+// early. A nil budget's channel is never closed.
+func (b *crawlBudget) done() <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+	return b.stopped
+}
+
+// release stops the deadline timer once the run is over
+func (b *crawlBudget) release() {
+	if b != nil && b.timer != nil {
+		b.timer.Stop()
+	}
+}
+
+// exhausted reports whether the run was stopped, stopping it first if its
+// time is up
+func (b *crawlBudget) exhausted() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exhaustedLocked()
+}
+
+// exhaustedLocked is exhausted for callers holding b.mu
+func (b *crawlBudget) exhaustedLocked() bool {
+	if b.reason == "" && !b.deadline.IsZero() && !b.now().Before(b.deadline) {
+		b.stopLocked(models.StopMaxDuration)
+	}
+	return b.reason != ""
+}
+
+// takePage reserves a fetch and reports whether the budget allows it. Pages
+// are counted when requested, so concurrent requests cannot overshoot.
+func (b *crawlBudget) takePage() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exhaustedLocked() {
+		return false
+	}
+	if b.maxPages > 0 && b.pages >= b.maxPages {
+		b.stopLocked(models.StopMaxPages)
+		return false
+	}
+	b.pages++
+	return true
+}
+
+// canSaveItem reports whether another item may be saved. Items found on
+// pages that were in flight when another limit was hit are still saved.
+func (b *crawlBudget) canSaveItem() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxItems > 0 && b.items >= b.maxItems {
+		b.stopLocked(models.StopMaxItems)
+		return false
+	}
+	return true
+}
+
+// addItem counts a new or updated item
+func (b *crawlBudget) addItem() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.items++
+	b.mu.Unlock()
+}
+
+// addBytes counts a response body and stops the run once the byte limit is reached
+func (b *crawlBudget) addBytes(n int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bytes += int64(n)
+	if b.maxBytes > 0 && b.bytes >= b.maxBytes {
+		b.stopLocked(models.StopMaxBytes)
+	}
+}
+
+// stopLocked records the first reason the run stopped. Callers must hold b.mu.
+func (b *crawlBudget) stopLocked(reason string) {
+	if b.reason != "" {
+		return
+	}
+	b.reason = reason
+	if b.onStop != nil {
+		b.onStop(reason)
+	}
+	close(b.stopped)
+}
+
+// stopReason returns the limit that stopped the run, or "" if none did
+func (b *crawlBudget) stopReason() string {
+	if b == nil {
+		return ""
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reason
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestCrawlBudgetLimits(t *testing.T) {
+	if newCrawlBudget(ScrapeOptions{}, time.Now(), nil) != nil {
+		t.Error("Expected no budget without limits")
+	}
+	var none *crawlBudget
+	if !none.takePage() || !none.canSaveItem() || none.exhausted() {
+		t.Error("Expected a nil budget to allow everything")
+	}
+
+	var reasons []string
+	stop := func(reason string) { reasons = append(reasons, reason) }
+
+	pages := newCrawlBudget(ScrapeOptions{MaxPages: 2}, time.Now(), stop)
+	if !pages.takePage() || !pages.takePage() {
+		t.Fatal("Expected the first two pages to be allowed")
+	}
+	if pages.exhausted() {
+		t.Error("Expected the budget to stop only once a page is refused")
+	}
+	if pages.takePage() || !pages.exhausted() || pages.stopReason() != models.StopMaxPages {
+		t.Errorf("Expected the third page to stop the run with %s, got %q", models.StopMaxPages, pages.stopReason())
+	}
+	select {
+	case <-pages.done():
+	default:
+		t.Error("Expected the done channel to be closed once the run stops")
+	}
+
+	items := newCrawlBudget(ScrapeOptions{MaxItems: 1}, time.Now(), stop)
+	if !items.canSaveItem() {
+		t.Fatal("Expected the first item to be allowed")
+	}
+	items.addItem()
+	if items.canSaveItem() || items.stopReason() != models.StopMaxItems {
+		t.Errorf("Expected the second item to stop the run with %s, got %q", models.StopMaxItems, items.stopReason())
+	}
+
+	bytes := newCrawlBudget(ScrapeOptions{MaxBytes: 100}, time.Now(), stop)
+	bytes.addBytes(60)
+	if bytes.exhausted() {
+		t.Error("Expected 60 of 100 bytes to leave the run going")
+	}
+	bytes.addBytes(60)
+	if !bytes.exhausted() || bytes.stopReason() != models.StopMaxBytes {
+		t.Errorf("Expected 120 bytes to stop the run with %s, got %q", models.StopMaxBytes, bytes.stopReason())
+	}
+
+	start := time.Now()
+	clock := start
+	duration := newCrawlBudget(ScrapeOptions{MaxDurationSeconds: 60, MaxPages: 1}, start, stop)
+	defer duration.release()
+	duration.now = func() time.Time { return clock }
+	if !duration.takePage() {
+		t.Fatal("Expected a page before the deadline to be allowed")
+	}
+	clock = start.Add(time.Minute)
+	if !duration.exhausted() || duration.stopReason() != models.StopMaxDuration {
+		t.Errorf("Expected the deadline to stop the run with %s, got %q", models.StopMaxDuration, duration.stopReason())
+	}
+	duration.takePage()
+	if duration.stopReason() != models.StopMaxDuration {
+		t.Errorf("Expected the first limit hit to be kept, got %q", duration.stopReason())
+	}
+
+	want := []string{models.StopMaxPages, models.StopMaxItems, models.StopMaxBytes, models.StopMaxDuration}
+	if fmt.Sprint(reasons) != fmt.Sprint(want) {
+		t.Errorf("Expected onStop to be called once per budget with %v, got %v", want, reasons)
+	}
+
+	if err := validateBudget(ScrapeOptions{MaxPages: -1}); err == nil {
+		t.Error("Expected a negative limit to be rejected")
+	}
+}
+
+func TestCrawlBudgetDeadlineClosesDone(t *testing.T) {
+	var reason string
+	budget := newCrawlBudget(ScrapeOptions{MaxDurationSeconds: 1}, time.Now().Add(-900*time.Millisecond), func(r string) { reason = r })
+	defer budget.release()
+
+	select {
+	case <-budget.done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the deadline to close the done channel")
+	}
+	if budget.stopReason() != models.StopMaxDuration || reason != models.StopMaxDuration {
+		t.Errorf("Expected the run to stop with %s, got %q", models.StopMaxDuration, budget.stopReason())
+	}
+}
+
+func TestCrawlStopsAtPageBudget(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><h1>Facets</h1>`)
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, `<a href="/facet/%d">Facet %d</a>`, i, i)
+		}
+		fmt.Fprint(w, `</body></html>`)
+	}))
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{URL: ts.URL + "/", IgnoreRobotsTxt: true, MaxPages: 3})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.State != models.JobStateSucceeded {
+		t.Errorf("Expected a job stopped by its budget to succeed, got %s (%s)", job.State, job.LastError)
+	}
+	if job.StopReason != models.StopMaxPages {
+		t.Errorf("Expected stop reason %s, got %q", models.StopMaxPages, job.StopReason)
+	}
+	mu.Lock()
+	fetched := hits
+	mu.Unlock()
+	if job.PagesVisited != 3 || fetched != 3 {
+		t.Errorf("Expected 3 pages to be fetched, got %d recorded and %d served", job.PagesVisited, fetched)
+	}
+	if job.BytesFetched == 0 {
+		t.Error("Expected the fetched bytes to be recorded")
+	}
+
+	// The unfetched pages stay queued for another run
+	if err := ResumeJob(jobID); err != nil {
+		t.Fatalf("Failed to resume job: %v", err)
+	}
+	job = waitForJob(t, jobID)
+	if job.PagesVisited != 6 || job.StopReason != models.StopMaxPages {
+		t.Errorf("Expected the resumed run to fetch 3 more pages and stop again, got %d pages, reason %q", job.PagesVisited, job.StopReason)
+	}
+}
+
+func TestBudgetStopKeepsSitemapCutoff(t *testing.T) {
+	setupItemStoreDB(t)
+
+	// Every page was last modified a day ago, before either run started
+	lastMod := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+			for i := 0; i < 5; i++ {
+				fmt.Fprintf(w, `<url><loc>http://%s/p/%d</loc><lastmod>%s</lastmod></url>`, r.Host, i, lastMod)
+			}
+			fmt.Fprint(w, `</urlset>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><body><h1>Page %s</h1></body></html>`, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	opts := ScrapeOptions{URL: ts.URL + "/", UseSitemaps: true, MaxPages: 2}
+	jobID, err := StartScraping(opts)
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	if job := waitForJob(t, jobID); job.StopReason != models.StopMaxPages || job.SitemapURLs != 5 {
+		t.Fatalf("Expected the first run to seed 5 pages and stop at its budget, got %d seeded, reason %q", job.SitemapURLs, job.StopReason)
+	}
+
+	// The first run never fetched most pages, so they are seeded again
+	opts.MaxPages = 0
+	jobID, err = StartScraping(opts)
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	if job := waitForJob(t, jobID); job.SitemapURLs != 5 {
+		t.Errorf("Expected a budget-stopped run to leave the lastmod cutoff alone, got %d pages seeded", job.SitemapURLs)
+	}
+
+	// A run that finished its frontier moves the cutoff past them
+	jobID, err = StartScraping(opts)
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	if job := waitForJob(t, jobID); job.SitemapURLs != 0 {
+		t.Errorf("Expected pages unchanged since the last full run to be skipped, got %d seeded", job.SitemapURLs)
+	}
+}
+
+func TestDeadlineEndsThrottleWait(t *testing.T) {
+	setupItemStoreDB(t)
+
+	// The host pauses the crawl for far longer than the job may run; the
+	// retry starts waiting after a second and the deadline hits during it
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "600")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	started := time.Now()
+	jobID, err := StartScraping(ScrapeOptions{URL: ts.URL + "/", IgnoreRobotsTxt: true, MaxDurationSeconds: 2})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if elapsed := time.Since(started); elapsed > 6*time.Second {
+		t.Errorf("Expected the job to stop near its 2s deadline, took %v", elapsed)
+	}
+	if job.StopReason != models.StopMaxDuration || job.FailedURLs != 0 {
+		t.Errorf("Expected the job to stop with %s and no failed URLs, got %q and %d", models.StopMaxDuration, job.StopReason, job.FailedURLs)
+	}
+	if pending, _ := pendingFrontierCount(jobID); pending != 1 {
+		t.Errorf("Expected the page to stay pending for a resume, got %d pending", pending)
+	}
+}
+
+func TestInvalidBudgetIsRejected(t *testing.T) {
+	if _, err := StartScraping(ScrapeOptions{URL: "https://example.com/", MaxDurationSeconds: -5}); err == nil {
+		t.Error("Expected a negative duration budget to be rejected")
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+// DefaultDeniedURLParams are tracking and session parameters stripped from
+// URLs before they are compared or stored. A trailing "*" matches any suffix.
+var DefaultDeniedURLParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gbraid",
+	"wbraid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_gl",
+	"ref_src",
+	"phpsessid",
+	"jsessionid",
+	"sessionid",
+}
+
+// urlNormalizer maps the different spellings of a page's URL to one
+// canonical form, used both to dedupe the crawl frontier and as the key
+// items are stored under
+type urlNormalizer struct {
+	mu      sync.RWMutex
+	allowed []string // When non-empty, only these query parameters are kept
+	denied  []string // Query parameters always dropped
+}
+
+var urlRules = &urlNormalizer{denied: DefaultDeniedURLParams}
+
+// SetURLParamRules configures which query parameters survive normalization.
+// With a non-empty allow list every other parameter is dropped; denied
+// parameters are dropped either way. Names are case-insensitive and may end
+// in "*" to match a prefix.
+func SetURLParamRules(allowed, denied []string) {
+	urlRules.mu.Lock()
+	urlRules.allowed = lowerAll(allowed)
+	urlRules.denied = lowerAll(denied)
+	urlRules.mu.Unlock()
+}
+
+// lowerAll lowercases and trims each entry, dropping empty ones
+func lowerAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// canonicalURL normalizes raw with the configured parameter rules. URLs that
+// cannot be parsed or are not http(s) are returned unchanged. The result is
+// only a key: pages are always fetched from the URL they were found under,
+// so http-only sites keep working.
+func canonicalURL(raw string) string {
+	return urlRules.normalize(raw)
+}
+
+// normalize upgrades http to https, lowercases the host, drops default
+// ports, fragments, trailing slashes and filtered query parameters, and
+// sorts the remaining parameters. Parameters without a value are kept, as
+// "print="
+func (n *urlNormalizer) normalize(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return raw
+	}
+	u.Scheme = "https"
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	// Clean dot segments and duplicate slashes, keeping the path escaped as found
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	p = path.Clean(p)
+	if p != "/" {
+		p = strings.TrimSuffix(p, "/")
+	}
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		u.Path = unescaped
+		u.RawPath = p
+	}
+
+	u.RawQuery = n.filterQuery(u.Query()).Encode()
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// filterQuery drops parameters rejected by the allow/deny lists and sorts
+// the values of the others. url.Values.Encode sorts them by key.
+func (n *urlNormalizer) filterQuery(query url.Values) url.Values {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for key, values := range query {
+		name := strings.ToLower(key)
+		if (len(n.allowed) > 0 && !paramMatchesAny(name, n.allowed)) || paramMatchesAny(name, n.denied) {
+			query.Del(key)
+			continue
+		}
+		sort.Strings(values)
+	}
+	return query
+}
+
+// paramMatchesAny checks a lowercased parameter name against exact and "prefix*" patterns
+func paramMatchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// pageCanonicalURL returns the page's <link rel="canonical"> as an absolute
+// http(s) URL, or "" when there is none
+func pageCanonicalURL(e *colly.HTMLElement) string {
+	root := e.DOM.Closest("html")
+	if root.Length() == 0 {
+		root = e.DOM
+	}
+
+	href := strings.TrimSpace(root.Find(`link[rel~="canonical"]`).First().AttrOr("href", ""))
+	if href == "" {
+		return ""
+	}
+
+	abs := e.Request.AbsoluteURL(href)
+	if u, err := url.Parse(abs); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return abs
+}
+
+// canonicalizeItemURL stores the item under its canonical URL and keeps the
+// URL it was extracted from as OriginalURL. Items describing the page itself
+// follow the page's rel="canonical" link.
+func canonicalizeItemURL(e *colly.HTMLElement, item *models.ScrapedItem) {
+	if item.OriginalURL == "" {
+		item.OriginalURL = item.URL
+	}
+
+	target := item.URL
+	if item.URL == e.Request.URL.String() {
+		if canonical := pageCanonicalURL(e); canonical != "" {
+			target = canonical
+		}
+	}
+	item.URL = canonicalURL(target)
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	n := &urlNormalizer{denied: DefaultDeniedURLParams}
+
+	tests := map[string]string{
+		"https://example.com/stove":                             "https://example.com/stove",
+		"http://Example.COM/stove/":                             "https://example.com/stove",
+		"https://example.com:443/stove#reviews":                 "https://example.com/stove",
+		"https://example.com/stove?utm_source=x&utm_medium=y":   "https://example.com/stove",
+		"https://example.com/stove?size=m&color=red&fbclid=abc": "https://example.com/stove?color=red&size=m",
+		"https://example.com/a/../stove?ref=":                   "https://example.com/stove?ref=",
+		"https://example.com/stove?print&size=m":                "https://example.com/stove?print=&size=m",
+		"https://example.com":                                   "https://example.com/",
+		"https://example.com:8080/stove":                        "https://example.com:8080/stove",
+		"https://example.com/caf%C3%A9/":                        "https://example.com/caf%C3%A9",
+		"mailto:shop@example.com":                               "mailto:shop@example.com",
+	}
+
+	for raw, want := range tests {
+		if got := n.normalize(raw); got != want {
+			t.Errorf("normalize(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestCanonicalURLAllowList(t *testing.T) {
+	n := &urlNormalizer{allowed: []string{"id", "page"}, denied: []string{"page"}}
+
+	got := n.normalize("https://example.com/item?id=7&sort=price&page=2&session=abc")
+	if want := "https://example.com/item?id=7"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestCanonicalizeItemURL(t *testing.T) {
+	html := `
+		<html>
+			<head><link rel="canonical" href="/products/stove/"></head>
+			<body><h1>Camp Stove</h1></body>
+		</html>
+	`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+
+	var page, other models.ScrapedItem
+	c := colly.NewCollector()
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		page = models.ScrapedItem{Title: "Camp Stove", URL: e.Request.URL.String()}
+		canonicalizeItemURL(e, &page)
+
+		other = models.ScrapedItem{Title: "Fuel", URL: e.Request.AbsoluteURL("/products/fuel?utm_source=list")}
+		canonicalizeItemURL(e, &other)
+	})
+	c.Visit(ts.URL + "/stove?utm_campaign=spring")
+
+	host := ts.Listener.Addr().String()
+	if want := "https://" + host + "/products/stove"; page.URL != want {
+		t.Errorf("Expected the page to follow rel=canonical to %q, got %q", want, page.URL)
+	}
+	if want := ts.URL + "/stove?utm_campaign=spring"; page.OriginalURL != want {
+		t.Errorf("Expected the original URL %q to be kept, got %q", want, page.OriginalURL)
+	}
+	if want := "https://" + host + "/products/fuel"; other.URL != want {
+		t.Errorf("Expected other items to ignore rel=canonical, got %q", other.URL)
+	}
+}
+
+func TestCanonicalURLIsOnlyAKey(t *testing.T) {
+	setupItemStoreDB(t)
+
+	// httptest serves plain http only, so fetching the https key would fail
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.RequestURI()]++
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Page %s</h1><a href="/list">All</a><a href="/list?print">Print</a></body></html>`, r.URL.RequestURI())
+	}))
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{URL: ts.URL + "/", IgnoreRobotsTxt: true})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+	if job.ErrorCount != 0 {
+		t.Errorf("Expected every page to be fetched over http, got %d errors (%s)", job.ErrorCount, job.LastError)
+	}
+
+	mu.Lock()
+	if hits["/list"] != 1 || hits["/list?print"] != 1 {
+		t.Errorf("Expected /list and /list?print to be fetched as different pages, got %v", hits)
+	}
+	mu.Unlock()
+
+	var printable models.ScrapedItem
+	key := "https://" + ts.Listener.Addr().String() + "/list?print="
+	if err := db.DB.Where("url = ?", key).First(&printable).Error; err != nil {
+		t.Fatalf("Expected the print view to be stored under %s: %v", key, err)
+	}
+	if want := ts.URL + "/list?print"; printable.OriginalURL != want {
+		t.Errorf("Expected the fetched URL %q to be kept, got %q", want, printable.OriginalURL)
+	}
+}
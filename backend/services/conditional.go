@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+	"gorm.io/gorm/clause"
+)
+
+// Context keys collecting what a page yields, stored with its validators
+const (
+	pageLinksCtx      = "pageLinks"
+	pageItemsCtx      = "pageItems"
+	pageIncompleteCtx = "pageIncomplete" // Set when an item of the page was not saved
+)
+
+// addPageValue appends value to the list kept under key in the page's context
+func addPageValue(r *colly.Request, key, value string) {
+	values, _ := r.Ctx.GetAny(key).([]string)
+	r.Ctx.Put(key, append(values, value))
+}
+
+// pageValues returns the list kept under key in the page's context as JSON,
+// without duplicates
+func pageValues(r *colly.Request, key string) string {
+	values, _ := r.Ctx.GetAny(key).([]string)
+
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+
+	data, _ := json.Marshal(unique)
+	return string(data)
+}
+
+// loadPageValidator returns the stored validators of a page, or nil
+func loadPageValidator(key string) *models.PageValidator {
+	var validators []models.PageValidator
+	if err := db.DB.Where("url = ?", key).Limit(1).Find(&validators).Error; err != nil || len(validators) == 0 {
+		return nil
+	}
+	return &validators[0]
+}
+
+// setConditionalHeaders asks the server to answer 304 Not Modified if the
+// page has not changed since its validators were stored
+func setConditionalHeaders(r *colly.Request) {
+	validator := loadPageValidator(requestKey(r))
+	if validator == nil {
+		return
+	}
+	if validator.ETag != "" {
+		r.Headers.Set("If-None-Match", validator.ETag)
+	}
+	if validator.LastModified != "" {
+		r.Headers.Set("If-Modified-Since", validator.LastModified)
+	}
+}
+
+// savePageValidator stores the ETag and Last-Modified of a fully fetched
+// page, with the links and items found on it. Validators of pages that no
+// longer send any are dropped, as are those of pages with an item that was
+// not saved, so the next crawl fetches them in full instead of taking the
+// missing item as unchanged.
+func savePageValidator(r *colly.Response) {
+	key := requestKey(r.Request)
+	etag, lastModified := r.Headers.Get("ETag"), r.Headers.Get("Last-Modified")
+	incomplete, _ := r.Ctx.GetAny(pageIncompleteCtx).(bool)
+	if incomplete || (etag == "" && lastModified == "") {
+		if err := db.DB.Where("url = ?", key).Delete(&models.PageValidator{}).Error; err != nil {
+			log.Printf("Error dropping validators of %s: %v", key, err)
+		}
+		return
+	}
+
+	row := models.PageValidator{
+		URL:          key,
+		ETag:         etag,
+		LastModified: lastModified,
+		Size:         int64(len(r.Body)),
+		Links:        pageValues(r.Request, pageLinksCtx),
+		ItemURLs:     pageValues(r.Request, pageItemsCtx),
+		UpdatedAt:    time.Now(),
+	}
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "url"}},
+		DoUpdates: clause.AssignmentColumns([]string{"e_tag", "last_modified", "size", "links", "item_urls", "updated_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		log.Printf("Error saving validators of %s: %v", key, err)
+	}
+}
+
+// replayUnchangedPage handles a 304 answer without extracting the page
+// again: the links found on its last full fetch are followed, so the crawl
+// still reaches changed pages below it, and its items count as unchanged.
+// Their stored prices are observed again for this job, so price history has
+// no gaps for pages that did not change.
+func (ctx *scrapingContext) replayUnchangedPage(c *colly.Collector, r *colly.Response) {
+	validator := loadPageValidator(requestKey(r.Request))
+	if validator == nil {
+		ctx.recordNotModified(0, 0)
+		return
+	}
+
+	var links []string
+	if err := json.Unmarshal([]byte(validator.Links), &links); err != nil {
+		log.Printf("Error reading stored links of %s: %v", validator.URL, err)
+	}
+	for _, link := range links {
+		if !ctx.scope.allows(link) {
+			continue
+		}
+		// Product links are stored with the others; like a resume, mark
+		// them in both sets so a later listing does not queue them again
+		ctx.markNew(ctx.productURLs, link)
+		if ctx.markNew(ctx.visitedURLs, link) {
+			followLink(c, r.Request, link)
+		}
+	}
+
+	var itemURLs []string
+	var items []models.ScrapedItem
+	if err := json.Unmarshal([]byte(validator.ItemURLs), &itemURLs); err == nil && len(itemURLs) > 0 {
+		if err := db.DB.Where("url IN ?", itemURLs).Find(&items).Error; err != nil {
+			log.Printf("Error loading items of %s: %v", validator.URL, err)
+		}
+	}
+
+	now := time.Now()
+	if ctx.upsert && len(items) > 0 {
+		ids := make([]uint, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		// Like an unchanged extraction, only last_seen_at moves
+		if err := db.DB.Model(&models.ScrapedItem{}).Where("id IN ?", ids).UpdateColumn("last_seen_at", now).Error; err != nil {
+			log.Printf("Error touching items of %s: %v", validator.URL, err)
+		}
+	}
+
+	var jobID string
+	if ctx.job != nil {
+		jobID = ctx.job.ID()
+	}
+	for _, item := range items {
+		item.ScrapedAt = now
+		recordPriceObservation(item.ID, item, jobID)
+	}
+
+	ctx.recordNotModified(validator.Size, len(items))
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+func TestConditionalRecrawl(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	versions := map[string]string{"/": "v1", "/catalog": "v1", "/stove": "v1", "/kettle": "v1"}
+	links := map[string]string{"/": "/catalog", "/catalog": "/stove", "/stove": "/kettle"}
+	full := make(map[string]int)
+	conditional := make(map[string]int)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		etag := `"` + versions[r.URL.Path] + `"`
+		if r.Header.Get("If-None-Match") != "" {
+			conditional[r.URL.Path]++
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		full[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Page %s %s</title></head><body>
+			<h1>Page %s</h1><p>Version %s</p><a href="%s">Next</a>
+		</body></html>`, r.URL.Path, versions[r.URL.Path], r.URL.Path, versions[r.URL.Path], links[r.URL.Path])
+	}))
+	defer ts.Close()
+
+	crawl := func(opts ScrapeOptions) models.ScrapeJob {
+		t.Helper()
+		opts.URL = ts.URL + "/"
+		opts.MaxDepth = 5
+		opts.IgnoreRobotsTxt = true
+		opts.Upsert = true
+		jobID, err := StartScraping(opts)
+		if err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		job := waitForJob(t, jobID)
+		if job.State != models.JobStateSucceeded {
+			t.Fatalf("Expected the crawl to succeed, got %s (%s)", job.State, job.LastError)
+		}
+		return job
+	}
+
+	first := crawl(ScrapeOptions{})
+	if first.NotModified != 0 || first.ItemsSaved != 4 {
+		t.Fatalf("Expected the first crawl to fetch and save all 4 pages, got %d not modified and %d saved", first.NotModified, first.ItemsSaved)
+	}
+	var stored int64
+	db.DB.Model(&models.PageValidator{}).Count(&stored)
+	if stored != 4 {
+		t.Fatalf("Expected validators for 4 pages, got %d", stored)
+	}
+
+	// Only the stove changed; the unchanged pages above it still lead there
+	mu.Lock()
+	versions["/stove"] = "v2"
+	mu.Unlock()
+
+	second := crawl(ScrapeOptions{})
+	if second.NotModified != 3 || second.PagesVisited != 1 {
+		t.Errorf("Expected 3 pages not modified and 1 fetched, got %d and %d", second.NotModified, second.PagesVisited)
+	}
+	if second.ItemsUpdated != 1 || second.ItemsUnchanged != 3 {
+		t.Errorf("Expected 1 item updated and 3 unchanged, got %d and %d", second.ItemsUpdated, second.ItemsUnchanged)
+	}
+	if second.BytesSaved == 0 {
+		t.Error("Expected the saved bytes to be recorded")
+	}
+
+	mu.Lock()
+	if full["/stove"] != 2 || full["/kettle"] != 1 || conditional["/kettle"] != 1 {
+		t.Errorf("Expected the stove to be refetched and the kettle to be checked, got full %v, conditional %v", full, conditional)
+	}
+	mu.Unlock()
+
+	third := crawl(ScrapeOptions{FullRecrawl: true})
+	if third.NotModified != 0 || third.PagesVisited != 4 {
+		t.Errorf("Expected a full recrawl to fetch all 4 pages, got %d not modified and %d fetched", third.NotModified, third.PagesVisited)
+	}
+}
+
+func TestConditionalRecrawlAfterRefusedItem(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	full := make(map[string]int)
+	pages := map[string]string{
+		"/":       `<h1 class="product-title">Camp Stove</h1><span class="price">$49.99</span><a href="/kettle">Kettle</a>`,
+		"/kettle": `<h1 class="product-title">Kettle</h1><span class="price">$19.99</span>`,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		mu.Lock()
+		full[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body>%s<button class="add-to-cart">Add</button></body></html>`, body)
+	}))
+	defer ts.Close()
+
+	crawl := func(opts ScrapeOptions) string {
+		t.Helper()
+		opts.URL = ts.URL + "/"
+		opts.IgnoreRobotsTxt = true
+		opts.Upsert = true
+		jobID, err := StartScraping(opts)
+		if err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		waitForJob(t, jobID)
+		return jobID
+	}
+
+	// The kettle is refused by the item budget, so its page keeps no validators
+	crawl(ScrapeOptions{MaxItems: 1})
+	var stored int64
+	db.DB.Model(&models.PageValidator{}).Count(&stored)
+	if stored != 1 {
+		t.Errorf("Expected validators only for the page whose item was saved, got %d", stored)
+	}
+
+	second := crawl(ScrapeOptions{})
+	mu.Lock()
+	if full["/"] != 1 || full["/kettle"] != 2 {
+		t.Errorf("Expected only the kettle to be fetched in full again, got %v", full)
+	}
+	mu.Unlock()
+
+	var kettle models.ScrapedItem
+	if err := db.DB.Where("url = ?", canonicalURL(ts.URL+"/kettle")).First(&kettle).Error; err != nil {
+		t.Fatalf("Expected the kettle to be saved by the second crawl: %v", err)
+	}
+
+	// The unchanged stove still gets a price observation for the second crawl
+	var stove models.ScrapedItem
+	if err := db.DB.Where("url = ?", canonicalURL(ts.URL+"/")).First(&stove).Error; err != nil {
+		t.Fatalf("Failed to load the stove: %v", err)
+	}
+	var observations []models.PriceObservation
+	db.DB.Where("item_id = ? AND job_id = ?", stove.ID, second).Find(&observations)
+	if len(observations) != 1 || observations[0].Price != stove.Price || observations[0].Currency != stove.Currency {
+		t.Errorf("Expected one observation of %v %s for the unchanged stove, got %+v", stove.Price, stove.Currency, observations)
+	}
+}
+
+func TestConditionalRecrawlWithSession(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	conditional := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Signed in visitors get a member price, with the same ETag
+		price := "$49.99"
+		if cookie, err := r.Cookie("session"); err == nil && cookie.Value == "member" {
+			price = "$39.99"
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1 class="product-title">Camp Stove</h1><span class="price">%s</span><button class="add-to-cart">Add</button></body></html>`, price)
+	}))
+	defer ts.Close()
+
+	crawl := func(opts ScrapeOptions) models.ScrapeJob {
+		t.Helper()
+		opts.URL = ts.URL + "/"
+		opts.IgnoreRobotsTxt = true
+		opts.Upsert = true
+		jobID, err := StartScraping(opts)
+		if err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		return waitForJob(t, jobID)
+	}
+
+	crawl(ScrapeOptions{})
+
+	// The signed in crawl is not answered from the anonymous validators
+	job := crawl(ScrapeOptions{Cookies: []CookieOption{{Name: "session", Value: "member"}}})
+	if job.NotModified != 0 || job.ItemsUpdated != 1 {
+		t.Errorf("Expected the page to be fetched in full and its item updated, got %d not modified and %d updated", job.NotModified, job.ItemsUpdated)
+	}
+	mu.Lock()
+	if conditional != 0 {
+		t.Errorf("Expected no conditional requests from a job with a session, got %d", conditional)
+	}
+	mu.Unlock()
+
+	// Nor does it leave validators an anonymous crawl would take for its own
+	if validator := loadPageValidator(canonicalURL(ts.URL + "/")); validator == nil || validator.Size == 0 {
+		t.Fatal("Expected the anonymous crawl's validators to be kept")
+	}
+	if job := crawl(ScrapeOptions{}); job.NotModified != 1 {
+		t.Errorf("Expected the anonymous crawl to revalidate the page, got %d not modified", job.NotModified)
+	}
+}
+
+func TestReplayMarksProductLinks(t *testing.T) {
+	setupItemStoreDB(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><h1>Page</h1></body></html>`)
+	}))
+	defer ts.Close()
+
+	start, _ := url.Parse(ts.URL + "/")
+	scope, err := newCrawlScope(ScrapeOptions{URL: start.String()}, start)
+	if err != nil {
+		t.Fatalf("Failed to create scope: %v", err)
+	}
+	db.DB.Create(&models.PageValidator{URL: canonicalURL(start.String()), ETag: `"v1"`, Links: `["` + ts.URL + `/kettle"]`})
+
+	ctx := &scrapingContext{
+		visitedURLs: make(map[string]bool),
+		productURLs: make(map[string]bool),
+		seenImages:  make(map[string]bool),
+		mu:          &sync.Mutex{},
+		scope:       scope,
+	}
+	page := &colly.Request{URL: start, Ctx: colly.NewContext(), Depth: 1}
+	ctx.replayUnchangedPage(colly.NewCollector(), &colly.Response{Request: page})
+
+	key := canonicalURL(ts.URL + "/kettle")
+	if !ctx.visitedURLs[key] || !ctx.productURLs[key] {
+		t.Errorf("Expected the replayed link to be marked as a plain and a product link, got %v and %v", ctx.visitedURLs, ctx.productURLs)
+	}
+}
+
+func TestPageValues(t *testing.T) {
+	req := &colly.Request{Ctx: colly.NewContext()}
+	for _, link := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/a"} {
+		addPageValue(req, pageLinksCtx, link)
+	}
+	if got := pageValues(req, pageLinksCtx); got != `["https://example.com/a","https://example.com/b"]` {
+		t.Errorf("Expected unique links in order, got %s", got)
+	}
+	if got := pageValues(req, pageItemsCtx); got != "[]" {
+		t.Errorf("Expected no items, got %s", got)
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoCredentialKey is returned when credentials are used without an encryption key
+	ErrNoCredentialKey = errors.New("no credential encryption key configured")
+	// ErrInvalidCredential is returned when a credential fails validation
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// CredentialSecrets are the parts of a SiteCredential stored encrypted
+type CredentialSecrets struct {
+	Auth        *AuthOptions      `json:"auth,omitempty"`
+	LoginFields map[string]string `json:"login_fields,omitempty"` // Filled into the login form, e.g. username and password
+	Headers     map[string]string `json:"headers,omitempty"`
+	Cookies     []CookieOption    `json:"cookies,omitempty"`
+}
+
+// empty reports whether no secret is set
+func (s CredentialSecrets) empty() bool {
+	return s.Auth == nil && len(s.LoginFields) == 0 && len(s.Headers) == 0 && len(s.Cookies) == 0
+}
+
+// register redacts the secret values from log output until the returned
+// func is called
+func (s CredentialSecrets) register() (unregister func()) {
+	return registerSessionSecrets(ScrapeOptions{
+		Auth:    s.Auth,
+		Login:   &LoginOptions{Fields: s.LoginFields},
+		Headers: s.Headers,
+		Cookies: s.Cookies,
+	})
+}
+
+// credentialKey is the AES-256-GCM cipher credentials are sealed with
+var credentialKey struct {
+	mu   sync.RWMutex
+	aead cipher.AEAD
+}
+
+// SetCredentialKey sets the 32-byte key credentials are encrypted with,
+// given base64 or hex encoded. An empty key disables stored credentials.
+func SetCredentialKey(encoded string) error {
+	var aead cipher.AEAD
+	if encoded = strings.TrimSpace(encoded); encoded != "" {
+		key, err := decodeCredentialKey(encoded)
+		if err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if aead, err = cipher.NewGCM(block); err != nil {
+			return err
+		}
+	}
+
+	credentialKey.mu.Lock()
+	credentialKey.aead = aead
+	credentialKey.mu.Unlock()
+	return nil
+}
+
+// decodeCredentialKey reads a 32-byte key in base64 or hex
+func decodeCredentialKey(encoded string) ([]byte, error) {
+	for _, decode := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := decode(encoded); err == nil && len(key) == 32 {
+			return key, nil
+		}
+	}
+	return nil, errors.New("credential key must be 32 bytes, base64 or hex encoded")
+}
+
+// credentialCipher returns the configured cipher
+func credentialCipher() (cipher.AEAD, error) {
+	credentialKey.mu.RLock()
+	defer credentialKey.mu.RUnlock()
+	if credentialKey.aead == nil {
+		return nil, ErrNoCredentialKey
+	}
+	return credentialKey.aead, nil
+}
+
+// sealSecrets encrypts secrets as base64 of a random nonce followed by the ciphertext
+func sealSecrets(secrets CredentialSecrets) (string, error) {
+	aead, err := credentialCipher()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// openSecrets decrypts secrets sealed by sealSecrets
+func openSecrets(sealed string) (CredentialSecrets, error) {
+	var secrets CredentialSecrets
+	aead, err := credentialCipher()
+	if err != nil {
+		return secrets, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return secrets, errors.New("credential secrets are corrupt")
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return secrets, errors.New("credential secrets cannot be decrypted with the configured key")
+	}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return secrets, err
+	}
+	return secrets, nil
+}
+
+// validateCredential checks a credential before it is stored
+func validateCredential(cred *models.SiteCredential, secrets CredentialSecrets) error {
+	if strings.TrimSpace(cred.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidCredential)
+	}
+	if cred.LoginURL != "" {
+		u, err := url.Parse(cred.LoginURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: login_url must be an absolute http(s) URL", ErrInvalidCredential)
+		}
+	}
+	if err := validateSessionOptions(ScrapeOptions{Auth: secrets.Auth, Cookies: secrets.Cookies}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+
+	var taken int64
+	if err := db.DB.Model(&models.SiteCredential{}).Where("name = ? AND id <> ?", cred.Name, cred.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("%w: name %q is already used", ErrInvalidCredential, cred.Name)
+	}
+	return nil
+}
+
+// CreateCredential encrypts the secrets and stores a new credential
+func CreateCredential(cred *models.SiteCredential, secrets CredentialSecrets) error {
+	if err := validateCredential(cred, secrets); err != nil {
+		return err
+	}
+	sealed, err := sealSecrets(secrets)
+	if err != nil {
+		return err
+	}
+	defer secrets.register()()
+
+	cred.Secret = sealed
+	return db.DB.Create(cred).Error
+}
+
+// UpdateCredential saves changes to a credential. Its secrets are replaced
+// when secrets is not empty and kept otherwise.
+func UpdateCredential(cred *models.SiteCredential, secrets CredentialSecrets) error {
+	if err := validateCredential(cred, secrets); err != nil {
+		return err
+	}
+	if !secrets.empty() {
+		sealed, err := sealSecrets(secrets)
+		if err != nil {
+			return err
+		}
+		defer secrets.register()()
+		cred.Secret = sealed
+	}
+	return db.DB.Save(cred).Error
+}
+
+// GetCredential loads a credential by ID. Its secrets stay encrypted.
+func GetCredential(id uint) (models.SiteCredential, error) {
+	var cred models.SiteCredential
+	err := db.DB.First(&cred, id).Error
+	return cred, err
+}
+
+// ListCredentials returns all credentials ordered by ID
+func ListCredentials() ([]models.SiteCredential, error) {
+	var creds []models.SiteCredential
+	err := db.DB.Order("id ASC").Find(&creds).Error
+	return creds, err
+}
+
+// DeleteCredential removes a credential. Jobs and schedules still naming it
+// fail to start.
+func DeleteCredential(id uint) error {
+	result := db.DB.Delete(&models.SiteCredential{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// withCredential returns opts with the login settings and decrypted secrets
+// of its credential filled in. Secrets from the credential win over ones
+// given inline; opts itself is not modified.
+func withCredential(opts ScrapeOptions) (ScrapeOptions, error) {
+	if opts.CredentialID == 0 {
+		return opts, nil
+	}
+
+	cred, err := GetCredential(opts.CredentialID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return opts, fmt.Errorf("%w: credential %d does not exist", ErrInvalidCredential, opts.CredentialID)
+	}
+	if err != nil {
+		return opts, err
+	}
+	secrets, err := openSecrets(cred.Secret)
+	if err != nil {
+		return opts, fmt.Errorf("credential %d: %w", cred.ID, err)
+	}
+
+	headers := make(map[string]string, len(opts.Headers)+len(secrets.Headers))
+	for name, value := range opts.Headers {
+		headers[name] = value
+	}
+	for name, value := range secrets.Headers {
+		headers[name] = value
+	}
+	opts.Headers = headers
+	opts.Cookies = append(append([]CookieOption{}, opts.Cookies...), secrets.Cookies...)
+	if secrets.Auth != nil {
+		opts.Auth = secrets.Auth
+	}
+
+	var login LoginOptions
+	switch {
+	case opts.Login != nil:
+		login = *opts.Login
+	case cred.LoginURL != "":
+		login = LoginOptions{
+			URL:           cred.LoginURL,
+			FormSelector:  cred.FormSelector,
+			SuccessText:   cred.SuccessText,
+			SuccessCookie: cred.SuccessCookie,
+		}
+	}
+	if login.URL != "" {
+		fields := make(map[string]string, len(login.Fields)+len(secrets.LoginFields))
+		for name, value := range login.Fields {
+			fields[name] = value
+		}
+		for name, value := range secrets.LoginFields {
+			fields[name] = value
+		}
+		login.Fields = fields
+		opts.Login = &login
+	}
+	return opts, nil
+}
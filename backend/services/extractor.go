@@ -0,0 +1,174 @@
+package services
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+// Extractor turns a fetched page into scraped items. The crawler hands each
+// page to the highest priority extractor whose Match returns true. Only if
+// that one returns no items is the next matching extractor tried, so a page
+// yields the items of a single extractor.
+type Extractor interface {
+	// Name identifies the extractor in logs and in the registry
+	Name() string
+	// Match reports whether the extractor handles the page. e is the page's
+	// <html> element; e.Request holds the URL.
+	Match(e *colly.HTMLElement) bool
+	// Extract returns the items found on the page. Items without a title or
+	// URL are dropped.
+	Extract(e *colly.HTMLElement) []models.ScrapedItem
+}
+
+// Priorities of the built-in extractors. Site-specific extractors usually sit
+// between ExtractorPriorityProfile and ExtractorPriorityWikipedia.
+const (
+	ExtractorPriorityProfile   = 100
+	ExtractorPriorityWikipedia = 50
+	ExtractorPriorityProduct   = 20
+	ExtractorPriorityGeneric   = 0
+)
+
+// registeredExtractor is an extractor with its place in the ordering
+type registeredExtractor struct {
+	extractor Extractor
+	priority  int
+}
+
+// extractorRegistry holds extractors ordered by descending priority.
+// Extractors with equal priority keep their registration order.
+type extractorRegistry struct {
+	mu      sync.RWMutex
+	entries []registeredExtractor
+}
+
+var extractors = newExtractorRegistry()
+
+// newExtractorRegistry returns a registry holding the built-in extractors
+func newExtractorRegistry() *extractorRegistry {
+	r := &extractorRegistry{}
+	r.register(profileExtractor{}, ExtractorPriorityProfile)
+	r.register(wikipediaExtractor{}, ExtractorPriorityWikipedia)
+	r.register(productExtractor{}, ExtractorPriorityProduct)
+	r.register(genericExtractor{}, ExtractorPriorityGeneric)
+	return r
+}
+
+// RegisterExtractor adds an extractor to the crawler. Higher priorities are
+// tried first. An extractor registered under an existing name replaces it,
+// which also allows overriding a built-in one.
+func RegisterExtractor(ext Extractor, priority int) {
+	extractors.register(ext, priority)
+}
+
+// register inserts or replaces the extractor and restores the ordering
+func (r *extractorRegistry) register(ext Extractor, priority int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, entry := range r.entries {
+		if entry.extractor.Name() == ext.Name() {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			break
+		}
+	}
+
+	r.entries = append(r.entries, registeredExtractor{extractor: ext, priority: priority})
+	sort.SliceStable(r.entries, func(i, j int) bool { return r.entries[i].priority > r.entries[j].priority })
+}
+
+// extract returns the items of the highest priority matching extractor that
+// finds any on the page, along with that extractor, or nil if none does
+func (r *extractorRegistry) extract(e *colly.HTMLElement) (Extractor, []models.ScrapedItem) {
+	// Extractors run without the lock, so one may register another
+	r.mu.RLock()
+	entries := append([]registeredExtractor(nil), r.entries...)
+	r.mu.RUnlock()
+
+	for _, entry := range entries {
+		if !entry.extractor.Match(e) {
+			continue
+		}
+		if items := entry.extractor.Extract(e); len(items) > 0 {
+			return entry.extractor, items
+		}
+	}
+	return nil, nil
+}
+
+// names lists the registered extractors in the order they are tried
+func (r *extractorRegistry) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.entries))
+	for i, entry := range r.entries {
+		names[i] = entry.extractor.Name()
+	}
+	return names
+}
+
+// itemList wraps an optional item as the slice an Extractor returns
+func itemList(item *models.ScrapedItem) []models.ScrapedItem {
+	if item == nil {
+		return nil
+	}
+	return []models.ScrapedItem{*item}
+}
+
+// profileExtractor applies the first configured extraction profile for the URL
+type profileExtractor struct{}
+
+func (profileExtractor) Name() string { return "profile" }
+
+func (profileExtractor) Match(e *colly.HTMLElement) bool {
+	return profiles.match(e.Request.URL) != nil
+}
+
+func (profileExtractor) Extract(e *colly.HTMLElement) []models.ScrapedItem {
+	profile := profiles.match(e.Request.URL)
+	if profile == nil {
+		return nil
+	}
+	return itemList(extractWithProfile(e, profile))
+}
+
+// wikipediaExtractor reads Wikipedia's article layout
+type wikipediaExtractor struct{}
+
+func (wikipediaExtractor) Name() string { return "wikipedia" }
+
+func (wikipediaExtractor) Match(e *colly.HTMLElement) bool {
+	return isWikipediaURL(e.Request.URL)
+}
+
+func (wikipediaExtractor) Extract(e *colly.HTMLElement) []models.ScrapedItem {
+	return itemList(extractWikipediaData(e))
+}
+
+// productExtractor handles pages that look like, or declare, a product
+type productExtractor struct{}
+
+func (productExtractor) Name() string { return "product" }
+
+func (productExtractor) Match(e *colly.HTMLElement) bool {
+	return hasProductIndicators(e) || pageStructuredData(e).isProduct()
+}
+
+func (productExtractor) Extract(e *colly.HTMLElement) []models.ScrapedItem {
+	return itemList(extractProductData(e))
+}
+
+// genericExtractor is the fallback for every other page
+type genericExtractor struct{}
+
+func (genericExtractor) Name() string { return "article" }
+
+func (genericExtractor) Match(e *colly.HTMLElement) bool { return true }
+
+func (genericExtractor) Extract(e *colly.HTMLElement) []models.ScrapedItem {
+	return itemList(extractGenericContentData(e))
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+// stubExtractor matches pages whose URL contains match. An empty one finds
+// no items on them.
+type stubExtractor struct {
+	name  string
+	match string
+	empty bool
+}
+
+func (s stubExtractor) Name() string { return s.name }
+
+func (s stubExtractor) Match(e *colly.HTMLElement) bool {
+	return strings.Contains(e.Request.URL.String(), s.match)
+}
+
+func (s stubExtractor) Extract(e *colly.HTMLElement) []models.ScrapedItem {
+	if s.empty {
+		return nil
+	}
+	return []models.ScrapedItem{{Title: s.name, URL: e.Request.URL.String()}}
+}
+
+// matchedExtractor serves html and returns the name of the extractor whose items the registry keeps for path
+func matchedExtractor(t *testing.T, r *extractorRegistry, html, path string) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+
+	var name string
+	c := colly.NewCollector()
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		if ext, _ := r.extract(e); ext != nil {
+			name = ext.Name()
+		}
+	})
+	if err := c.Visit(ts.URL + path); err != nil {
+		t.Fatalf("Failed to visit test page: %v", err)
+	}
+	return name
+}
+
+func TestExtractorRegistryOrdering(t *testing.T) {
+	r := newExtractorRegistry()
+	r.register(stubExtractor{name: "shop", match: "/shop/"}, 60)
+	r.register(stubExtractor{name: "late", match: "/shop/"}, 60)
+
+	want := []string{"profile", "shop", "late", "wikipedia", "product", "article"}
+	if got := r.names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected order %v, got %v", want, got)
+	}
+
+	// Re-registering a name moves it instead of adding a second entry
+	r.register(stubExtractor{name: "shop", match: "/shop/"}, 10)
+	want = []string{"profile", "late", "wikipedia", "product", "shop", "article"}
+	if got := r.names(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected order %v, got %v", want, got)
+	}
+}
+
+func TestExtractorRegistryMatch(t *testing.T) {
+	r := newExtractorRegistry()
+	r.register(stubExtractor{name: "shop", match: "/shop/"}, 60)
+	r.register(stubExtractor{name: "outlet", match: "/outlet/", empty: true}, 60)
+
+	productHTML := `
+		<html><body>
+			<h1 class="product-title">Camp Stove</h1>
+			<span class="price">$49.99</span>
+			<button class="add-to-cart">Add</button>
+		</body></html>
+	`
+	articleHTML := `<html><body><h1>Trip Report</h1><p>We went camping.</p></body></html>`
+
+	tests := []struct {
+		html, path, want string
+	}{
+		{productHTML, "/shop/stove", "shop"},
+		{productHTML, "/stove", "product"},
+		{productHTML, "/outlet/stove", "product"}, // The outlet extractor finds nothing there
+		{articleHTML, "/blog/trip", "article"},
+	}
+	for _, tt := range tests {
+		if got := matchedExtractor(t, r, tt.html, tt.path); got != tt.want {
+			t.Errorf("Expected %s to be handled by %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+	"gorm.io/gorm/clause"
+)
+
+// frontierKeyCtx carries a request's canonical URL from OnRequest to the
+// response callbacks, which may see a redirected URL
+const frontierKeyCtx = "frontierKey"
+
+// crawlFrontier persists the URLs a job has queued and which of them are
+// finished. A nil frontier records nothing.
+type crawlFrontier struct {
+	jobID string
+}
+
+// newCrawlFrontier returns the persistent frontier of a job
+func newCrawlFrontier(jobID string) *crawlFrontier {
+	return &crawlFrontier{jobID: jobID}
+}
+
+// add records a queued request as pending. URLs already in the frontier keep
+// their state.
+func (f *crawlFrontier) add(r *colly.Request) {
+	if f == nil {
+		return
+	}
+
+	key := canonicalURL(r.URL.String())
+	r.Ctx.Put(frontierKeyCtx, key)
+
+	row := models.FrontierURL{
+		JobID:    f.jobID,
+		URL:      key,
+		FetchURL: r.URL.String(),
+		Depth:    r.Depth,
+		State:    models.FrontierPending,
+	}
+	if err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		log.Printf("[job %s] Error queuing %s in frontier: %v", f.jobID, key, err)
+	}
+}
+
+// requestKey returns the canonical URL a request is tracked under, which
+// stays the same when the request is redirected
+func requestKey(r *colly.Request) string {
+	if key := r.Ctx.Get(frontierKeyCtx); key != "" {
+		return key
+	}
+	return canonicalURL(r.URL.String())
+}
+
+// mark moves the request's URL to a final state
+func (f *crawlFrontier) mark(r *colly.Request, state string) {
+	f.update(r, map[string]interface{}{"state": state})
+}
+
+// fail records that the request's URL failed on its last attempt
+func (f *crawlFrontier) fail(r *colly.Request, attempts, status int, fetchErr error) {
+	f.update(r, map[string]interface{}{
+		"state":       models.FrontierFailed,
+		"attempts":    attempts,
+		"status_code": status,
+		"last_error":  fetchErr.Error(),
+	})
+}
+
+// update changes the frontier row of the request's URL
+func (f *crawlFrontier) update(r *colly.Request, fields map[string]interface{}) {
+	if f == nil {
+		return
+	}
+
+	key := requestKey(r)
+	err := db.DB.Model(&models.FrontierURL{}).
+		Where("job_id = ? AND url = ?", f.jobID, key).
+		Updates(fields).Error
+	if err != nil {
+		log.Printf("[job %s] Error updating %s in frontier: %v", f.jobID, key, err)
+	}
+}
+
+// load returns the pending URLs to requeue and the canonical URLs of every
+// URL the job has already queued
+func (f *crawlFrontier) load() ([]models.FrontierURL, []string, error) {
+	var rows []models.FrontierURL
+	if err := db.DB.Where("job_id = ?", f.jobID).Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var pending []models.FrontierURL
+	seen := make([]string, 0, len(rows))
+	for _, row := range rows {
+		seen = append(seen, row.URL)
+		if row.State == models.FrontierPending {
+			pending = append(pending, row)
+		}
+	}
+	return pending, seen, nil
+}
+
+// clear deletes the frontier of a finished job. Frontiers with failed URLs
+// are kept, so retrying them does not refetch the pages that succeeded.
+func (f *crawlFrontier) clear() {
+	if f == nil {
+		return
+	}
+
+	var failed int64
+	err := db.DB.Model(&models.FrontierURL{}).
+		Where("job_id = ? AND state = ?", f.jobID, models.FrontierFailed).
+		Count(&failed).Error
+	if err != nil || failed > 0 {
+		return
+	}
+
+	if err := db.DB.Where("job_id = ?", f.jobID).Delete(&models.FrontierURL{}).Error; err != nil {
+		log.Printf("[job %s] Error clearing frontier: %v", f.jobID, err)
+	}
+}
+
+// pendingFrontierCount returns how many queued URLs a job has not fetched yet
+func pendingFrontierCount(jobID string) (int64, error) {
+	var count int64
+	err := db.DB.Model(&models.FrontierURL{}).
+		Where("job_id = ? AND state = ?", jobID, models.FrontierPending).
+		Count(&count).Error
+	return count, err
+}
+
+// failedFrontierURLs returns the pages of a job that failed on their last attempt
+func failedFrontierURLs(jobID string) ([]models.FrontierURL, error) {
+	var rows []models.FrontierURL
+	err := db.DB.Where("job_id = ? AND state = ?", jobID, models.FrontierFailed).
+		Order("id ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// requeueFailedURLs moves a job's failed pages back to pending and returns
+// how many were requeued
+func requeueFailedURLs(jobID string) (int64, error) {
+	result := db.DB.Model(&models.FrontierURL{}).
+		Where("job_id = ? AND state = ?", jobID, models.FrontierFailed).
+		Updates(map[string]interface{}{
+			"state":       models.FrontierPending,
+			"attempts":    0,
+			"status_code": 0,
+			"last_error":  "",
+		})
+	return result.RowsAffected, result.Error
+}
+
+// frontierRequest rebuilds a queued request at its original depth, so the
+// depth limit applies as if the crawl had never stopped
+func frontierRequest(c *colly.Collector, row models.FrontierURL) (*colly.Request, error) {
+	return crawlRequest(c, row.FetchURL, row.Depth, nil)
+}
+
+// crawlRequest builds a GET request at the given depth with a context of its
+// own, seeded with values
+func crawlRequest(c *colly.Collector, rawURL string, depth int, values map[string]interface{}) (*colly.Request, error) {
+	serialized, err := json.Marshal(map[string]interface{}{
+		"URL":    rawURL,
+		"Method": "GET",
+		"Depth":  depth,
+		"Ctx":    values,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.UnmarshalRequest(serialized)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestResumeJobSkipsFinishedPages(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Page %s</h1><a href="/">Home</a><article><a href="/a">A</a></article><a href="/c">C</a></body></html>`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	config, _ := json.Marshal(ScrapeOptions{URL: ts.URL + "/", MaxDepth: 3})
+	record := models.ScrapeJob{
+		ID:        "resumetest",
+		TargetURL: ts.URL + "/",
+		Config:    string(config),
+		State:     models.JobStateFailed,
+		LastError: "interrupted by server restart",
+	}
+	db.DB.Create(&record)
+
+	frontier := []models.FrontierURL{
+		{URL: canonicalURL(ts.URL + "/"), FetchURL: ts.URL + "/", Depth: 1, State: models.FrontierDone},
+		{URL: canonicalURL(ts.URL + "/a"), FetchURL: ts.URL + "/a", Depth: 2, State: models.FrontierDone},
+		{URL: canonicalURL(ts.URL + "/b"), FetchURL: ts.URL + "/b", Depth: 2, State: models.FrontierPending},
+	}
+	for _, row := range frontier {
+		row.JobID = record.ID
+		db.DB.Create(&row)
+	}
+
+	if err := ResumeJob(record.ID); err != nil {
+		t.Fatalf("Failed to resume job: %v", err)
+	}
+	if err := ResumeJob(record.ID); err != ErrJobActive {
+		t.Errorf("Expected resuming a running job to fail with ErrJobActive, got %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	var job models.ScrapeJob
+	for {
+		job, _ = GetJob(record.ID)
+		if !job.IsActive() || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if job.State != models.JobStateSucceeded {
+		t.Fatalf("Expected the resumed job to succeed, got %s (%s)", job.State, job.LastError)
+	}
+	if job.ResumeCount != 1 {
+		t.Errorf("Expected resume_count to be 1, got %d", job.ResumeCount)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/"] != 0 || hits["/a"] != 0 {
+		t.Errorf("Expected finished pages not to be fetched again, got %v", hits)
+	}
+	if hits["/b"] != 1 || hits["/c"] != 1 {
+		t.Errorf("Expected the pending page and its new link to be fetched once, got %v", hits)
+	}
+
+	if pending, _ := pendingFrontierCount(record.ID); pending != 0 {
+		t.Errorf("Expected the frontier to be cleared, %d URLs still pending", pending)
+	}
+	if err := ResumeJob(record.ID); err != ErrNothingToResume {
+		t.Errorf("Expected ErrNothingToResume for a finished job, got %v", err)
+	}
+}
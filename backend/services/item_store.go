@@ -0,0 +1,130 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// saveOutcome describes what storing a scraped item did to the database
+type saveOutcome int
+
+const (
+	itemCreated   saveOutcome = iota // First time the URL was seen
+	itemUpdated                      // Content changed and the row was rewritten
+	itemUnchanged                    // Row left alone apart from last_seen_at
+)
+
+// itemContentHash hashes the extracted fields after normalizing whitespace,
+// so cosmetic markup changes do not count as edits
+func itemContentHash(item models.ScrapedItem) string {
+	fields := []string{
+		item.Title,
+		item.Description,
+		item.ImageURL,
+		strconv.FormatFloat(item.Price, 'f', -1, 64),
+		item.Currency,
+		item.PriceText,
+		item.Metadata,
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		h.Write([]byte(strings.Join(strings.Fields(field), " ")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// createScrapedItem inserts a new item with its first version. It returns
+// false, and no error, when the URL was inserted concurrently, as jobs run
+// in parallel and may find the same page.
+func createScrapedItem(item *models.ScrapedItem, jobID string) (bool, error) {
+	created := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "url"}},
+			DoNothing: true,
+		}).Create(item)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		return recordItemVersion(tx, *item, jobID)
+	})
+	return created, err
+}
+
+// storeScrapedItem inserts the item if its URL is new. Otherwise, with upsert
+// set, it rewrites the stored row when the content hash changed and only
+// touches last_seen_at when it did not. Without upsert existing rows are
+// never modified. New and changed content is snapshotted as an item version.
+// item is updated with the stored row's ID.
+func storeScrapedItem(item *models.ScrapedItem, upsert bool, jobID string) (saveOutcome, error) {
+	now := time.Now()
+	item.ContentHash = itemContentHash(*item)
+	item.LastSeenAt = now
+	item.LastChangedAt = now
+
+	var existing models.ScrapedItem
+	err := db.DB.Where("url = ?", item.URL).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		created, createErr := createScrapedItem(item, jobID)
+		if createErr != nil || created {
+			return itemCreated, createErr
+		}
+		// Another job stored the URL since the lookup; carry on from its row
+		err = db.DB.Where("url = ?", item.URL).First(&existing).Error
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	item.ID = existing.ID
+	if !upsert {
+		return itemUnchanged, nil
+	}
+
+	if existing.ContentHash == item.ContentHash {
+		err := db.DB.Model(&existing).UpdateColumn("last_seen_at", now).Error
+		return itemUnchanged, err
+	}
+
+	return itemUpdated, db.DB.Transaction(func(tx *gorm.DB) error {
+		// Items stored before versioning existed get their old state kept first
+		var versions int64
+		if err := tx.Model(&models.ItemVersion{}).Where("item_id = ?", existing.ID).Count(&versions).Error; err != nil {
+			return err
+		}
+		if versions == 0 {
+			if err := recordItemVersion(tx, existing, ""); err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&existing).Updates(map[string]interface{}{
+			"title":           item.Title,
+			"description":     item.Description,
+			"image_url":       item.ImageURL,
+			"price":           item.Price,
+			"currency":        item.Currency,
+			"price_text":      item.PriceText,
+			"metadata":        item.Metadata,
+			"content_hash":    item.ContentHash,
+			"last_seen_at":    now,
+			"last_changed_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return recordItemVersion(tx, *item, jobID)
+	})
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupItemStoreDB points the db package at a fresh in-memory database
+func setupItemStoreDB(t *testing.T) {
+	conn, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	// Every connection to ":memory:" is a separate database
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := conn.AutoMigrate(&models.ScrapedItem{}, &models.ItemVersion{}, &models.PriceObservation{}, &models.ScrapeJob{}, &models.FrontierURL{}, &models.SiteCredential{}, &models.PageValidator{}); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+
+	previous := db.DB
+	db.DB = conn
+	t.Cleanup(func() { db.DB = previous })
+}
+
+func TestItemContentHashIgnoresWhitespace(t *testing.T) {
+	a := models.ScrapedItem{Title: "Camp  Stove\n", Description: "Folds flat.", Price: 49.99}
+	b := models.ScrapedItem{Title: " Camp Stove", Description: "Folds\tflat.", Price: 49.99}
+	if itemContentHash(a) != itemContentHash(b) {
+		t.Error("Expected whitespace-only differences to hash the same")
+	}
+
+	b.Price = 44.99
+	if itemContentHash(a) == itemContentHash(b) {
+		t.Error("Expected a price change to change the hash")
+	}
+}
+
+func TestStoreScrapedItem(t *testing.T) {
+	setupItemStoreDB(t)
+
+	item := models.ScrapedItem{Title: "Camp Stove", URL: "https://example.com/stove", Price: 49.99}
+	if outcome, err := storeScrapedItem(&item, true, ""); err != nil || outcome != itemCreated {
+		t.Fatalf("Expected the first save to create the item, got %v (%v)", outcome, err)
+	}
+
+	again := item
+	again.ID = 0
+	if outcome, _ := storeScrapedItem(&again, true, ""); outcome != itemUnchanged {
+		t.Errorf("Expected identical content to be unchanged, got %v", outcome)
+	}
+
+	changed := models.ScrapedItem{Title: "Camp Stove", URL: item.URL, Price: 44.99}
+	if outcome, _ := storeScrapedItem(&changed, false, ""); outcome != itemUnchanged {
+		t.Errorf("Expected insert-only mode to leave the item alone, got %v", outcome)
+	}
+	if outcome, _ := storeScrapedItem(&changed, true, ""); outcome != itemUpdated {
+		t.Errorf("Expected changed content to update the item, got %v", outcome)
+	}
+
+	var stored models.ScrapedItem
+	db.DB.First(&stored, item.ID)
+	if stored.Price != 44.99 {
+		t.Errorf("Expected the stored price to be updated to 44.99, got %v", stored.Price)
+	}
+	if !stored.LastChangedAt.After(item.LastChangedAt) {
+		t.Error("Expected last_changed_at to move forward on update")
+	}
+
+	versions, _ := ListItemVersions(item.ID)
+	if len(versions) != 2 {
+		t.Fatalf("Expected a version for the insert and one for the update, got %d", len(versions))
+	}
+	changes := diffItemVersions(versions[0], versions[1])
+	if len(changes) != 1 || changes[0].Field != "price" {
+		t.Errorf("Expected only the price to differ, got %+v", changes)
+	}
+}
+
+func TestStoreScrapedItemRacingInsert(t *testing.T) {
+	setupItemStoreDB(t)
+
+	// Another job stores the URL between the lookup and the insert
+	other := models.ScrapedItem{Title: "Camp Stove", URL: "https://example.com/stove", Price: 52.99}
+	other.ContentHash = itemContentHash(other)
+	var once sync.Once
+	db.DB.Callback().Query().After("gorm:query").Register("test:racing_insert", func(tx *gorm.DB) {
+		if tx.Statement.Table == "scraped_items" && tx.RowsAffected == 0 {
+			once.Do(func() { db.DB.Create(&other) })
+		}
+	})
+
+	item := models.ScrapedItem{Title: "Camp Stove", URL: other.URL, Price: 49.99}
+	outcome, err := storeScrapedItem(&item, true, "")
+	if err != nil {
+		t.Fatalf("Expected the racing insert not to fail the save, got %v", err)
+	}
+	if outcome != itemUpdated || item.ID != other.ID {
+		t.Errorf("Expected the other job's row %d to be updated, got %v on row %d", other.ID, outcome, item.ID)
+	}
+
+	var count int64
+	db.DB.Model(&models.ScrapedItem{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected a single row for the URL, got %d", count)
+	}
+}
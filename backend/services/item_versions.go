@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/gorm"
+)
+
+// ErrVersionNotFound is returned when an item has no version with the requested number
+var ErrVersionNotFound = errors.New("item version not found")
+
+// FieldChange is one difference between two versions of an item. Metadata
+// keys are reported individually as "metadata.<key>".
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// recordItemVersion snapshots the item as the next version in its history
+func recordItemVersion(tx *gorm.DB, item models.ScrapedItem, jobID string) error {
+	var latest int
+	if err := tx.Model(&models.ItemVersion{}).
+		Where("item_id = ?", item.ID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.ItemVersion{
+		ItemID:      item.ID,
+		Version:     latest + 1,
+		JobID:       jobID,
+		Title:       item.Title,
+		Description: item.Description,
+		URL:         item.URL,
+		ImageURL:    item.ImageURL,
+		Price:       item.Price,
+		Currency:    item.Currency,
+		PriceText:   item.PriceText,
+		Metadata:    item.Metadata,
+		ContentHash: item.ContentHash,
+	}).Error
+}
+
+// ListItemVersions returns an item's versions, oldest first
+func ListItemVersions(itemID uint) ([]models.ItemVersion, error) {
+	var versions []models.ItemVersion
+	err := db.DB.Where("item_id = ?", itemID).Order("version ASC").Find(&versions).Error
+	return versions, err
+}
+
+// getItemVersion loads one version of an item. A version of 0 means the latest.
+func getItemVersion(itemID uint, version int) (models.ItemVersion, error) {
+	query := db.DB.Where("item_id = ?", itemID)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Order("version DESC")
+	}
+
+	var v models.ItemVersion
+	if err := query.First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return v, ErrVersionNotFound
+		}
+		return v, err
+	}
+	return v, nil
+}
+
+// ItemDiff is the set of changes between two versions of an item
+type ItemDiff struct {
+	ItemID      uint          `json:"item_id"`
+	FromVersion int           `json:"from_version"`
+	ToVersion   int           `json:"to_version"`
+	FromTime    time.Time     `json:"from_time"`
+	ToTime      time.Time     `json:"to_time"`
+	Changes     []FieldChange `json:"changes"`
+}
+
+// DiffItemVersions compares two versions of an item. A to of 0 means the
+// latest version, and a from of 0 means the version before to.
+func DiffItemVersions(itemID uint, from, to int) (ItemDiff, error) {
+	newer, err := getItemVersion(itemID, to)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+
+	if from == 0 {
+		from = newer.Version - 1
+		if from < 1 {
+			from = newer.Version
+		}
+	}
+	older, err := getItemVersion(itemID, from)
+	if err != nil {
+		return ItemDiff{}, err
+	}
+
+	changes := diffItemVersions(older, newer)
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+
+	return ItemDiff{
+		ItemID:      itemID,
+		FromVersion: older.Version,
+		ToVersion:   newer.Version,
+		FromTime:    older.CreatedAt,
+		ToTime:      newer.CreatedAt,
+		Changes:     changes,
+	}, nil
+}
+
+// diffItemVersions lists the fields that differ between two versions
+func diffItemVersions(a, b models.ItemVersion) []FieldChange {
+	var changes []FieldChange
+
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"title", a.Title, b.Title},
+		{"description", a.Description, b.Description},
+		{"url", a.URL, b.URL},
+		{"image_url", a.ImageURL, b.ImageURL},
+		{"price", a.Price, b.Price},
+		{"currency", a.Currency, b.Currency},
+		{"price_text", a.PriceText, b.PriceText},
+	}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+
+	return append(changes, diffMetadata(a.Metadata, b.Metadata)...)
+}
+
+// diffMetadata compares two metadata documents key by key. Keys present on
+// only one side are reported with a nil value on the other.
+func diffMetadata(a, b string) []FieldChange {
+	var before, after map[string]interface{}
+	json.Unmarshal([]byte(a), &before)
+	json.Unmarshal([]byte(b), &after)
+
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, key := range sorted {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changes = append(changes, FieldChange{Field: "metadata." + key, From: before[key], To: after[key]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+// DefaultMaxConcurrentJobs is the number of scraping jobs allowed to run at once
+const DefaultMaxConcurrentJobs = 4
+
+var (
+	// ErrJobNotFound is returned when no job exists with the given ID
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotActive is returned when a finished job is asked to change state
+	ErrJobNotActive = errors.New("job is not queued or running")
+	// ErrJobActive is returned when a queued or running job is asked to resume
+	ErrJobActive = errors.New("job is already queued or running")
+	// ErrNothingToResume is returned when a job has no unfetched URLs left
+	ErrNothingToResume = errors.New("job has no pending URLs to resume")
+	// ErrNoFailedURLs is returned when retrying a job that has no failed URLs
+	ErrNoFailedURLs = errors.New("job has no failed URLs")
+	// ErrSecretsNotStored is returned when restarting a job whose inline secrets were redacted
+	ErrSecretsNotStored = errors.New("job used inline secrets, which are not stored; use a stored credential to restart it")
+)
+
+// ScrapeOptions describes the crawl requested for a job
+type ScrapeOptions struct {
+	URL                string            `json:"url"`
+	MaxDepth           int               `json:"max_depth"`
+	IgnoreRobotsTxt    bool              `json:"ignore_robots_txt"` // For sites we own
+	UseSitemaps        bool              `json:"use_sitemaps"`
+	SitemapSince       *time.Time        `json:"sitemap_since,omitempty"`   // Defaults to the last successful crawl of URL
+	Upsert             bool              `json:"upsert"`                    // Update items whose content changed instead of only inserting new ones
+	Proxies            []string          `json:"proxies,omitempty"`         // Replaces the default proxies for this job
+	ProxySelection     string            `json:"proxy_selection,omitempty"` // ProxyRoundRobin or ProxySticky
+	Headers            map[string]string `json:"headers,omitempty"`         // Sent with every request, overriding the user agent if given
+	Cookies            []CookieOption    `json:"cookies,omitempty"`
+	Auth               *AuthOptions      `json:"auth,omitempty"`
+	Login              *LoginOptions     `json:"login,omitempty"`                // Submitted before the crawl; the session cookies are kept for the job
+	CredentialID       uint              `json:"credential_id,omitempty"`        // Stored credential whose secrets are decrypted when the job runs
+	Scope              string            `json:"scope,omitempty"`                // ScopeSameHost (default), ScopeSameDomain or ScopeSamePrefix
+	AllowedHosts       []string          `json:"allowed_hosts,omitempty"`        // Extra hosts to crawl, exact or "*.domain"
+	Include            []string          `json:"include,omitempty"`              // Only fetch URLs matching one of these globs or "re:" regexes
+	Exclude            []string          `json:"exclude,omitempty"`              // Never fetch URLs matching one of these
+	MaxPages           int               `json:"max_pages,omitempty"`            // Pages fetched per run, 0 for no limit
+	MaxItems           int               `json:"max_items,omitempty"`            // New or updated items per run
+	MaxBytes           int64             `json:"max_bytes,omitempty"`            // Response bytes per run
+	MaxDurationSeconds int               `json:"max_duration_seconds,omitempty"` // Wall-clock time per run
+	FullRecrawl        bool              `json:"full_recrawl,omitempty"`         // Fetch and extract every page in full, without conditional GETs
+}
+
+// Job is a scraping run tracked by the job manager. The record is shared
+// between the runner and API readers, so all access goes through the mutex.
+type Job struct {
+	Options ScrapeOptions
+	Resumed bool // Continue from the persisted frontier instead of the start URL
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	record models.ScrapeJob
+}
+
+// ID returns the job identifier
+func (j *Job) ID() string {
+	return j.record.ID
+}
+
+// Snapshot returns a copy of the job record
+func (j *Job) Snapshot() models.ScrapeJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.record
+}
+
+// Cancelled reports whether cancellation was requested for the job
+func (j *Job) Cancelled() bool {
+	return j.ctx.Err() != nil
+}
+
+// Done returns a channel that is closed when cancellation is requested
+func (j *Job) Done() <-chan struct{} {
+	return j.ctx.Done()
+}
+
+// update applies fn to the job record while holding the job lock
+func (j *Job) update(fn func(r *models.ScrapeJob)) {
+	j.mu.Lock()
+	fn(&j.record)
+	j.mu.Unlock()
+}
+
+// JobRunner performs the work for a job
+type JobRunner func(job *Job) error
+
+// JobManager tracks scraping jobs and bounds how many of them run at once.
+// Jobs submitted while the cap is reached wait in the queued state.
+type JobManager struct {
+	mu            sync.Mutex
+	cond          *sync.Cond
+	jobs          map[string]*Job
+	running       int
+	maxConcurrent int
+
+	// persist is called with a snapshot whenever a job changes state
+	persist func(models.ScrapeJob)
+}
+
+var jobManager = newPersistentJobManager(DefaultMaxConcurrentJobs)
+
+// NewJobManager creates a job manager allowing maxConcurrent jobs to run at once.
+// A value of zero or less means no limit.
+func NewJobManager(maxConcurrent int) *JobManager {
+	m := &JobManager{
+		jobs:          make(map[string]*Job),
+		maxConcurrent: maxConcurrent,
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// newPersistentJobManager creates a job manager that stores job records in the database
+func newPersistentJobManager(maxConcurrent int) *JobManager {
+	m := NewJobManager(maxConcurrent)
+	m.persist = saveJobRecord
+	return m
+}
+
+// SetMaxConcurrent changes the global cap on running jobs
+func (m *JobManager) SetMaxConcurrent(n int) {
+	m.mu.Lock()
+	m.maxConcurrent = n
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// Submit registers a new job and runs it in the background once a slot is free
+func (m *JobManager) Submit(opts ScrapeOptions, run JobRunner) *Job {
+	// Inline secrets stay in memory for this run only
+	config, _ := json.Marshal(opts.redacted())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	job := &Job{
+		Options: opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		record: models.ScrapeJob{
+			ID:        newJobID(),
+			TargetURL: opts.URL,
+			Config:    string(config),
+			State:     models.JobStateQueued,
+			CreatedAt: time.Now(),
+		},
+	}
+
+	m.mu.Lock()
+	m.jobs[job.ID()] = job
+	m.mu.Unlock()
+	m.save(job)
+
+	go m.run(job, run)
+
+	return job
+}
+
+// Resume queues a finished job again under its existing ID. Its counters
+// carry on from record, and the runner sees Job.Resumed set.
+func (m *JobManager) Resume(record models.ScrapeJob, opts ScrapeOptions, run JobRunner) (*Job, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	record.State = models.JobStateQueued
+	record.FinishedAt = nil
+	record.LastError = ""
+	record.StopReason = ""
+	record.ResumeCount++
+
+	job := &Job{
+		Options: opts,
+		Resumed: true,
+		ctx:     ctx,
+		cancel:  cancel,
+		record:  record,
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.jobs[job.ID()]; ok && existing.Snapshot().IsActive() {
+		m.mu.Unlock()
+		cancel()
+		return nil, ErrJobActive
+	}
+	m.jobs[job.ID()] = job
+	m.mu.Unlock()
+	m.save(job)
+
+	go m.run(job, run)
+
+	return job, nil
+}
+
+// run waits for a free slot, executes the job and records its outcome
+func (m *JobManager) run(job *Job, run JobRunner) {
+	defer job.cancel()
+
+	m.mu.Lock()
+	for m.maxConcurrent > 0 && m.running >= m.maxConcurrent && !job.Cancelled() {
+		m.cond.Wait()
+	}
+	if job.Cancelled() {
+		// Cancelled while still queued; it never takes a slot
+		m.mu.Unlock()
+		m.finish(job, nil)
+		return
+	}
+	m.running++
+	m.mu.Unlock()
+
+	job.update(func(r *models.ScrapeJob) {
+		now := time.Now()
+		r.State = models.JobStateRunning
+		r.StartedAt = &now
+	})
+	m.save(job)
+
+	err := run(job)
+	m.finish(job, err)
+
+	m.mu.Lock()
+	m.running--
+	m.mu.Unlock()
+	m.cond.Broadcast()
+}
+
+// finish records the final state of a job
+func (m *JobManager) finish(job *Job, err error) {
+	job.update(func(r *models.ScrapeJob) {
+		now := time.Now()
+		r.FinishedAt = &now
+		switch {
+		case job.Cancelled():
+			r.State = models.JobStateCancelled
+		case err != nil:
+			r.State = models.JobStateFailed
+			r.LastError = err.Error()
+		default:
+			r.State = models.JobStateSucceeded
+		}
+	})
+	m.save(job)
+}
+
+// Cancel requests that a queued or running job stop. A running job stops
+// queuing new requests and finishes once in-flight requests have drained.
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !job.Snapshot().IsActive() {
+		return ErrJobNotActive
+	}
+
+	job.cancel()
+	// Wake queued jobs so a cancelled one can leave the queue
+	m.cond.Broadcast()
+	return nil
+}
+
+// save hands the current job record to the persistence hook, if any
+func (m *JobManager) save(job *Job) {
+	if m.persist != nil {
+		m.persist(job.Snapshot())
+	}
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *JobManager) Get(id string) (models.ScrapeJob, bool) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	m.mu.Unlock()
+
+	if !ok {
+		return models.ScrapeJob{}, false
+	}
+	return job.Snapshot(), true
+}
+
+// RunningCount returns the number of jobs currently running
+func (m *JobManager) RunningCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.running
+}
+
+// Active returns snapshots of all queued and running jobs
+func (m *JobManager) Active() []models.ScrapeJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var active []models.ScrapeJob
+	for _, job := range m.jobs {
+		if snapshot := job.Snapshot(); snapshot.IsActive() {
+			active = append(active, snapshot)
+		}
+	}
+	return active
+}
+
+// Reset forgets all finished jobs. Their records remain in the database.
+func (m *JobManager) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if !job.Snapshot().IsActive() {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// SetMaxConcurrentJobs changes the global cap on concurrently running scraping jobs
+func SetMaxConcurrentJobs(n int) {
+	jobManager.SetMaxConcurrent(n)
+}
+
+// CancelJob requests cancellation of the scraping job with the given ID
+func CancelJob(id string) error {
+	return jobManager.Cancel(id)
+}
+
+// RunningJobCount returns the number of scraping jobs currently running
+func RunningJobCount() int {
+	return jobManager.RunningCount()
+}
+
+// GetActiveJobs returns all queued and running scraping jobs
+func GetActiveJobs() []models.ScrapeJob {
+	return jobManager.Active()
+}
+
+// newJobID generates a random identifier for a job
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000000")))
+	}
+	return hex.EncodeToString(b)
+}
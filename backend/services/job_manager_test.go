@@ -0,0 +1,130 @@
+package services
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestJobManagerAssignsUniqueIDs(t *testing.T) {
+	m := NewJobManager(0)
+	done := func(job *Job) error { return nil }
+
+	first := m.Submit(ScrapeOptions{URL: "https://example.com", MaxDepth: 1}, done)
+	second := m.Submit(ScrapeOptions{URL: "https://example.org", MaxDepth: 1}, done)
+
+	if first.ID() == "" || second.ID() == "" {
+		t.Fatal("Expected jobs to be assigned IDs")
+	}
+
+	if first.ID() == second.ID() {
+		t.Errorf("Expected unique job IDs, got %s twice", first.ID())
+	}
+}
+
+func TestJobManagerRespectsConcurrencyCap(t *testing.T) {
+	m := NewJobManager(2)
+
+	var current, peak int32
+	release := make(chan struct{})
+	run := func(job *Job) error {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&current, -1)
+		return nil
+	}
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, m.Submit(ScrapeOptions{URL: "https://example.com", MaxDepth: 1}, run).ID())
+	}
+
+	// Give the goroutines time to pick up their slots
+	time.Sleep(50 * time.Millisecond)
+
+	if running := m.RunningCount(); running != 2 {
+		t.Errorf("Expected 2 running jobs, got %d", running)
+	}
+
+	queued := 0
+	for _, id := range ids {
+		if job, _ := m.Get(id); job.State == models.JobStateQueued {
+			queued++
+		}
+	}
+	if queued != 2 {
+		t.Errorf("Expected 2 queued jobs, got %d", queued)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for m.RunningCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("Expected at most 2 concurrent jobs, got %d", p)
+	}
+
+	for _, id := range ids {
+		if job, _ := m.Get(id); job.State != models.JobStateSucceeded {
+			t.Errorf("Expected job %s to succeed, got state %s", id, job.State)
+		}
+	}
+}
+
+func TestJobManagerCancelRunningJob(t *testing.T) {
+	m := NewJobManager(1)
+
+	started := make(chan struct{})
+	run := func(job *Job) error {
+		close(started)
+		<-job.Done()
+		return nil
+	}
+
+	running := m.Submit(ScrapeOptions{URL: "https://example.com"}, run)
+	<-started
+
+	// A second job waits for the only slot
+	queued := m.Submit(ScrapeOptions{URL: "https://example.org"}, func(job *Job) error { return nil })
+
+	if err := m.Cancel(queued.ID()); err != nil {
+		t.Fatalf("Expected queued job to be cancellable, got %v", err)
+	}
+	if err := m.Cancel(running.ID()); err != nil {
+		t.Fatalf("Expected running job to be cancellable, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		a, _ := m.Get(running.ID())
+		b, _ := m.Get(queued.ID())
+		if !a.IsActive() && !b.IsActive() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, job := range []*Job{running, queued} {
+		if snapshot := job.Snapshot(); snapshot.State != models.JobStateCancelled {
+			t.Errorf("Expected job %s to be cancelled, got %s", job.ID(), snapshot.State)
+		}
+	}
+
+	if err := m.Cancel(running.ID()); err != ErrJobNotActive {
+		t.Errorf("Expected ErrJobNotActive for finished job, got %v", err)
+	}
+
+	if err := m.Cancel("missing"); err != ErrJobNotFound {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/gorm"
+)
+
+// saveJobRecord writes a job snapshot to the database
+func saveJobRecord(record models.ScrapeJob) {
+	if err := db.DB.Save(&record).Error; err != nil {
+		log.Printf("Error saving job %s: %v", record.ID, err)
+	}
+}
+
+// GetJob returns the scraping job with the given ID. Jobs that are still
+// tracked in memory are returned with their live counters.
+func GetJob(id string) (models.ScrapeJob, error) {
+	if job, ok := jobManager.Get(id); ok {
+		return job, nil
+	}
+
+	var job models.ScrapeJob
+	if err := db.DB.First(&job, "id = ?", id).Error; err != nil {
+		return models.ScrapeJob{}, err
+	}
+	return job, nil
+}
+
+// ListJobs returns scraping jobs ordered by creation time, newest first,
+// optionally filtered by state
+func ListJobs(limit, offset int, state string) ([]models.ScrapeJob, int64, error) {
+	var jobs []models.ScrapeJob
+	var totalCount int64
+
+	query := db.DB.Model(&models.ScrapeJob{})
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&jobs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Prefer live counters for jobs that are still in progress
+	for i := range jobs {
+		if live, ok := jobManager.Get(jobs[i].ID); ok {
+			jobs[i] = live
+		}
+	}
+
+	return jobs, totalCount, nil
+}
+
+// lastSuccessfulCrawl returns when the most recent successful job for the URL
+// started, or the zero time if it has never been crawled. Jobs stopped by a
+// budget are left out since they did not get through their frontier.
+func lastSuccessfulCrawl(targetURL string) time.Time {
+	var job models.ScrapeJob
+	err := db.DB.Where("target_url = ? AND state = ?", targetURL, models.JobStateSucceeded).
+		Where("stop_reason = '' OR stop_reason IS NULL").
+		Order("started_at DESC").
+		First(&job).Error
+	if err != nil || job.StartedAt == nil {
+		return time.Time{}
+	}
+	return *job.StartedAt
+}
+
+// MarkInterruptedJobs fails jobs left queued or running by a previous process
+func MarkInterruptedJobs() error {
+	now := time.Now()
+	return db.DB.Model(&models.ScrapeJob{}).
+		Where("state IN ?", []string{models.JobStateQueued, models.JobStateRunning}).
+		Updates(map[string]interface{}{
+			"state":       models.JobStateFailed,
+			"finished_at": now,
+			"last_error":  "interrupted by server restart",
+		}).Error
+}
+
+// ResumeJob continues a failed or cancelled job from its persisted frontier.
+// Pages it already fetched are not requested again.
+func ResumeJob(id string) error {
+	record, err := inactiveJob(id)
+	if err != nil {
+		return err
+	}
+
+	opts, err := restartOptions(record)
+	if err != nil {
+		return err
+	}
+
+	pending, err := pendingFrontierCount(id)
+	if err != nil {
+		return err
+	}
+	if pending == 0 {
+		return ErrNothingToResume
+	}
+
+	if err := resumeJobRecord(record, opts); err != nil {
+		return err
+	}
+	log.Printf("Resuming scraping job %s with %d pending URLs", id, pending)
+	return nil
+}
+
+// RetryFailedURLs requeues the pages of a finished job that failed on their
+// last attempt and runs the job again for just those pages and the links
+// found on them. Returns the number of requeued pages.
+func RetryFailedURLs(id string) (int64, error) {
+	record, err := inactiveJob(id)
+	if err != nil {
+		return 0, err
+	}
+
+	opts, err := restartOptions(record)
+	if err != nil {
+		return 0, err
+	}
+
+	requeued, err := requeueFailedURLs(id)
+	if err != nil {
+		return 0, err
+	}
+	if requeued == 0 {
+		return 0, ErrNoFailedURLs
+	}
+
+	record.FailedURLs -= int(requeued)
+	if record.FailedURLs < 0 {
+		record.FailedURLs = 0
+	}
+
+	if err := resumeJobRecord(record, opts); err != nil {
+		return 0, err
+	}
+	log.Printf("Retrying %d failed URLs of scraping job %s", requeued, id)
+	return requeued, nil
+}
+
+// GetFailedURLs returns the pages of a job that still failed after their last attempt
+func GetFailedURLs(id string) ([]models.FrontierURL, error) {
+	if _, err := GetJob(id); err != nil {
+		return nil, err
+	}
+	return failedFrontierURLs(id)
+}
+
+// inactiveJob returns the record of a job that is neither queued nor running
+func inactiveJob(id string) (models.ScrapeJob, error) {
+	record, err := GetJob(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ScrapeJob{}, ErrJobNotFound
+	}
+	if err != nil {
+		return models.ScrapeJob{}, err
+	}
+	if record.IsActive() {
+		return models.ScrapeJob{}, ErrJobActive
+	}
+	return record, nil
+}
+
+// restartOptions reads back the options a job was started with, which a
+// restart must be able to run with
+func restartOptions(record models.ScrapeJob) (ScrapeOptions, error) {
+	var opts ScrapeOptions
+	if err := json.Unmarshal([]byte(record.Config), &opts); err != nil {
+		return opts, err
+	}
+	if opts.hasRedactedSecrets() {
+		return opts, ErrSecretsNotStored
+	}
+	return opts, nil
+}
+
+// resumeJobRecord runs a job again from the pending URLs of its frontier
+func resumeJobRecord(record models.ScrapeJob, opts ScrapeOptions) error {
+	_, err := jobManager.Resume(record, opts, runScrapeJob)
+	return err
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// itemSyntax names the attributes used by an inline structured data format
+type itemSyntax struct {
+	scope    string // Attribute marking an element as an item
+	itemType string // Attribute holding the item's type
+	property string // Attribute naming a property of the enclosing item
+}
+
+var (
+	microdataSyntax = itemSyntax{scope: "itemscope", itemType: "itemtype", property: "itemprop"}
+	rdfaSyntax      = itemSyntax{scope: "typeof", itemType: "typeof", property: "property"}
+)
+
+// parseMicrodata builds the tree of top-level itemscope items under root.
+// Items use the same map shape as JSON-LD nodes, with types in "@type".
+func parseMicrodata(root *goquery.Selection) []interface{} {
+	return parseInlineItems(root, microdataSyntax)
+}
+
+// parseRDFa builds the tree of top-level typeof resources under root
+func parseRDFa(root *goquery.Selection) []interface{} {
+	return parseInlineItems(root, rdfaSyntax)
+}
+
+// parseInlineItems collects every item that is not itself a property of another item
+func parseInlineItems(root *goquery.Selection, syntax itemSyntax) []interface{} {
+	var items []interface{}
+	root.Find("[" + syntax.scope + "]").Each(func(_ int, s *goquery.Selection) {
+		if _, nested := s.Attr(syntax.property); nested {
+			return
+		}
+		items = append(items, buildInlineItem(s, syntax))
+	})
+	return items
+}
+
+// buildInlineItem reads an item's type and properties, descending into nested items
+func buildInlineItem(s *goquery.Selection, syntax itemSyntax) map[string]interface{} {
+	item := map[string]interface{}{}
+
+	if types := strings.Fields(s.AttrOr(syntax.itemType, "")); len(types) > 0 {
+		list := make([]interface{}, len(types))
+		for i, t := range types {
+			list[i] = schemaEnum(t)
+		}
+		item["@type"] = list
+	}
+
+	collectInlineProperties(s, syntax, item)
+	return item
+}
+
+// collectInlineProperties adds the properties found below s to item. Elements
+// that start a new item contribute that item as a value and are not descended into.
+func collectInlineProperties(s *goquery.Selection, syntax itemSyntax, item map[string]interface{}) {
+	s.Children().Each(func(_ int, child *goquery.Selection) {
+		names, isProperty := child.Attr(syntax.property)
+		_, isScope := child.Attr(syntax.scope)
+
+		if isProperty {
+			var value interface{}
+			if isScope {
+				value = buildInlineItem(child, syntax)
+			} else {
+				value = inlinePropertyValue(child)
+			}
+			for _, name := range strings.Fields(names) {
+				addInlineProperty(item, schemaEnum(name), value)
+			}
+		}
+
+		if !isScope {
+			collectInlineProperties(child, syntax, item)
+		}
+	})
+}
+
+// addInlineProperty stores a value, turning repeated properties into lists
+func addInlineProperty(item map[string]interface{}, name string, value interface{}) {
+	existing, ok := item[name]
+	if !ok {
+		item[name] = value
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		item[name] = append(list, value)
+		return
+	}
+	item[name] = []interface{}{existing, value}
+}
+
+// inlinePropertyValue returns a property's value following the microdata and
+// RDFa rules: an explicit content attribute wins, then the element's URL or
+// machine-readable attribute, then its text
+func inlinePropertyValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+
+	var attr string
+	switch goquery.NodeName(s) {
+	case "a", "area", "link":
+		attr = "href"
+	case "img", "audio", "video", "source", "track", "embed", "iframe":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		attr = "datetime"
+	}
+	if attr != "" {
+		if value, ok := s.Attr(attr); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	// RDFa resources may be named explicitly
+	if resource, ok := s.Attr("resource"); ok {
+		return strings.TrimSpace(resource)
+	}
+
+	return strings.TrimSpace(s.Text())
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestParseMicrodataProduct(t *testing.T) {
+	html := `
+		<html><body>
+			<div itemscope itemtype="https://schema.org/Product">
+				<h1 itemprop="name">Camp Stove</h1>
+				<img itemprop="image" src="/img/stove.jpg">
+				<p itemprop="description">Folds flat.</p>
+				<div itemprop="brand" itemscope itemtype="https://schema.org/Brand">
+					<span itemprop="name">Acme</span>
+				</div>
+				<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+					<span itemprop="price" content="49.99">$49.99</span>
+					<meta itemprop="priceCurrency" content="USD">
+					<link itemprop="availability" href="https://schema.org/InStock">
+				</div>
+			</div>
+		</body></html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sd := parseStructuredData(doc.Selection)
+
+	if !sd.isProduct() {
+		t.Fatalf("Expected a Product, got %+v", sd)
+	}
+
+	item := models.ScrapedItem{}
+	metadata := map[string]interface{}{}
+	sd.apply(&item, metadata)
+
+	if item.Title != "Camp Stove" {
+		t.Errorf("Expected title 'Camp Stove', got '%s'", item.Title)
+	}
+	if item.Price != 49.99 {
+		t.Errorf("Expected price 49.99, got %v", item.Price)
+	}
+	if item.Currency != "USD" {
+		t.Errorf("Expected currency 'USD', got '%s'", item.Currency)
+	}
+	if item.ImageURL != "/img/stove.jpg" {
+		t.Errorf("Expected image '/img/stove.jpg', got '%s'", item.ImageURL)
+	}
+
+	expected := map[string]string{
+		"brand":        "Acme",
+		"availability": "InStock",
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("Expected metadata %s to be '%s', got '%v'", key, value, metadata[key])
+		}
+	}
+
+	if _, ok := metadata["microdata"]; !ok {
+		t.Error("Expected raw microdata to be kept in metadata")
+	}
+	if _, ok := metadata["jsonld"]; ok {
+		t.Error("Expected no JSON-LD metadata for a microdata-only page")
+	}
+}
+
+func TestParseRDFaArticle(t *testing.T) {
+	html := `
+		<html><body>
+			<article vocab="https://schema.org/" typeof="BlogPosting">
+				<h1 property="headline">Packing Light</h1>
+				<time property="datePublished" datetime="2024-03-02">March 2</time>
+				<div property="author" typeof="Person">
+					<span property="name">Sam Hiker</span>
+				</div>
+			</article>
+		</body></html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sd := parseStructuredData(doc.Selection)
+
+	if sd == nil || sd.Type != "Article" {
+		t.Fatalf("Expected an Article, got %+v", sd)
+	}
+	if sd.Name != "Packing Light" {
+		t.Errorf("Expected headline to be used as name, got '%s'", sd.Name)
+	}
+	if sd.DatePublished != "2024-03-02" {
+		t.Errorf("Expected datePublished '2024-03-02', got '%s'", sd.DatePublished)
+	}
+	if sd.Author != "Sam Hiker" {
+		t.Errorf("Expected author 'Sam Hiker', got '%s'", sd.Author)
+	}
+}
+
+func TestJSONLDTakesPriorityOverMicrodata(t *testing.T) {
+	html := `
+		<html><head>
+			<script type="application/ld+json">{"@type": "Product", "name": "From JSON-LD"}</script>
+		</head><body>
+			<div itemscope itemtype="https://schema.org/Product">
+				<span itemprop="name">From microdata</span>
+			</div>
+		</body></html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sd := parseStructuredData(doc.Selection)
+
+	if sd == nil || sd.Name != "From JSON-LD" {
+		t.Errorf("Expected the JSON-LD product to win, got %+v", sd)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// priceInfo is what a piece of price text resolves to
+type priceInfo struct {
+	Amount    float64 // Current price, or the low end of a range
+	MaxAmount float64 // High end of a price range, 0 if the text is not a range
+	WasAmount float64 // Regular price when the text shows a sale, 0 otherwise
+	Currency  string  // ISO 4217 code, empty if the text names none
+	Raw       string  // Original text, trimmed
+}
+
+var (
+	// priceNumberPattern matches digit-grouped numbers first so "1 299,00"
+	// and "1.299.000" are read as one amount rather than several
+	priceNumberPattern = regexp.MustCompile(`\d{1,3}(?:[.,'\x{00a0}\x{202f} ]\d{3})+(?:[.,]\d+)?|\d+(?:[.,]\d+)?`)
+
+	// isoCodePattern matches candidate ISO 4217 codes such as "CHF 45"
+	isoCodePattern = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+	// priceRangePattern matches the text joining two ends of a range
+	priceRangePattern = regexp.MustCompile(`(?i)[-–—~]|\bto\b|\bbis\b|\bà\b|\bhasta\b`)
+
+	// wasPricePattern marks the amount that follows as the regular price
+	wasPricePattern = regexp.MustCompile(`(?i)\b(was|reg|regular|orig|original|list|msrp|rrp|uvp|statt|avant)\b`)
+)
+
+// isoCurrencies lists the ISO 4217 codes recognised in price text
+var isoCurrencies = map[string]bool{
+	"AED": true, "ARS": true, "AUD": true, "BGN": true, "BRL": true, "CAD": true,
+	"CHF": true, "CLP": true, "CNY": true, "COP": true, "CZK": true, "DKK": true,
+	"EGP": true, "EUR": true, "GBP": true, "HKD": true, "HUF": true, "IDR": true,
+	"ILS": true, "INR": true, "ISK": true, "JPY": true, "KRW": true, "MXN": true,
+	"MYR": true, "NOK": true, "NZD": true, "PHP": true, "PLN": true, "RON": true,
+	"RUB": true, "SAR": true, "SEK": true, "SGD": true, "THB": true, "TRY": true,
+	"TWD": true, "UAH": true, "USD": true, "VND": true, "ZAR": true,
+}
+
+// currencySymbols maps symbols to currencies, longest first so "US$" is
+// found before "$". Ambiguous symbols are resolved by symbolCurrency.
+var currencySymbols = []struct {
+	symbol   string
+	currency string
+}{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"AU$", "AUD"}, {"NZ$", "NZD"},
+	{"HK$", "HKD"}, {"MX$", "MXN"}, {"NT$", "TWD"}, {"R$", "BRL"},
+	{"C$", "CAD"}, {"A$", "AUD"}, {"S$", "SGD"},
+	{"zł", "PLN"}, {"Kč", "CZK"}, {"Fr.", "CHF"},
+	{"€", "EUR"}, {"£", "GBP"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"},
+	{"₺", "TRY"}, {"₪", "ILS"}, {"₫", "VND"}, {"฿", "THB"}, {"₱", "PHP"},
+	{"₴", "UAH"}, {"¥", ""}, {"kr", ""}, {"$", ""},
+}
+
+// zeroDecimalCurrencies have no minor unit, so "¥3,000" can only be grouping
+var zeroDecimalCurrencies = map[string]bool{
+	"JPY": true, "KRW": true, "VND": true, "CLP": true, "ISK": true, "IDR": true,
+}
+
+// decimalCommaLanguages write "1.299,00" rather than "1,299.00"
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true,
+	"et": true, "fi": true, "fr": true, "hr": true, "hu": true, "id": true,
+	"it": true, "lt": true, "lv": true, "nb": true, "nl": true, "nn": true,
+	"no": true, "pl": true, "pt": true, "ro": true, "ru": true, "sk": true,
+	"sl": true, "sr": true, "sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// decimalPointRegions override their language's convention
+var decimalPointRegions = map[string]bool{
+	"ch": true, "li": true, "mx": true,
+}
+
+// parsePrice reads an amount and currency from price text such as
+// "1.299,00 €", "CHF 45", "¥3,000", "from $9.99", "$10 - $20" or
+// "Was $20 Now $15". locale is the page language (e.g. "de-DE") and decides
+// ambiguous separators like "1.299"; it may be empty.
+func parsePrice(text, locale string) priceInfo {
+	info := priceInfo{Raw: strings.TrimSpace(text)}
+	if info.Raw == "" {
+		return info
+	}
+
+	language, region := splitLocale(locale)
+	info.Currency = detectCurrency(info.Raw, language, region)
+
+	matches := priceNumberPattern.FindAllStringIndex(info.Raw, -1)
+	amounts := make([]float64, 0, len(matches))
+	was := -1
+	for i, m := range matches {
+		amount, ok := parseAmount(info.Raw[m[0]:m[1]], language, region, info.Currency)
+		if !ok {
+			continue
+		}
+
+		// Text before this amount, back to the previous one
+		start := 0
+		if i > 0 {
+			start = matches[i-1][1]
+		}
+		if wasPricePattern.MatchString(info.Raw[start:m[0]]) {
+			was = len(amounts)
+		}
+		amounts = append(amounts, amount)
+	}
+
+	switch {
+	case len(amounts) == 0:
+		return info
+	case len(amounts) == 1:
+		info.Amount = amounts[0]
+	case was >= 0:
+		info.WasAmount = amounts[was]
+		info.Amount = amounts[len(amounts)-1]
+		if was == len(amounts)-1 {
+			info.Amount = amounts[0]
+		}
+	case priceRangePattern.MatchString(info.Raw[matches[0][1]:matches[1][0]]):
+		info.Amount, info.MaxAmount = minMax(amounts[0], amounts[1])
+	default:
+		// Two bare amounts are a struck-through price next to the sale price
+		info.Amount, info.WasAmount = minMax(amounts[0], amounts[1])
+	}
+
+	return info
+}
+
+// metadata adds the range and sale details that have no column of their own
+func (p priceInfo) metadata(metadata map[string]interface{}) {
+	if p.MaxAmount > 0 {
+		metadata["priceMax"] = p.MaxAmount
+	}
+	if p.WasAmount > 0 {
+		metadata["wasPrice"] = p.WasAmount
+	}
+}
+
+// splitLocale lowercases a locale like "de-AT" or "pt_BR" into language and region
+func splitLocale(locale string) (string, string) {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	language, region, _ := strings.Cut(locale, "-")
+	return language, region
+}
+
+// detectCurrency finds the currency named in the text, preferring ISO codes
+func detectCurrency(text, language, region string) string {
+	for _, code := range isoCodePattern.FindAllString(text, -1) {
+		if isoCurrencies[code] {
+			return code
+		}
+	}
+
+	for _, s := range currencySymbols {
+		if !strings.Contains(text, s.symbol) {
+			continue
+		}
+		if s.currency != "" {
+			return s.currency
+		}
+		return symbolCurrency(s.symbol, language, region)
+	}
+	return ""
+}
+
+// symbolCurrency resolves symbols shared by several currencies using the locale
+func symbolCurrency(symbol, language, region string) string {
+	switch symbol {
+	case "$":
+		regional := map[string]string{
+			"ca": "CAD", "au": "AUD", "nz": "NZD", "mx": "MXN", "sg": "SGD", "hk": "HKD",
+		}
+		if code, ok := regional[region]; ok {
+			return code
+		}
+		return "USD"
+	case "¥":
+		if language == "zh" {
+			return "CNY"
+		}
+		return "JPY"
+	case "kr":
+		switch language {
+		case "da":
+			return "DKK"
+		case "nb", "nn", "no":
+			return "NOK"
+		case "is":
+			return "ISK"
+		}
+		return "SEK"
+	}
+	return ""
+}
+
+// parseAmount converts a matched number, working out which separator is the
+// decimal mark
+func parseAmount(number, language, region, currency string) (float64, bool) {
+	// Spaces and apostrophes only ever group thousands
+	number = strings.NewReplacer(" ", "", "'", "", "\u00a0", "", "\u202f", "").Replace(number)
+
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+
+	var decimal string
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: whichever comes last is the decimal mark
+		decimal = "."
+		if lastComma > lastDot {
+			decimal = ","
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		sep := "."
+		if lastComma >= 0 {
+			sep = ","
+		}
+		decimal = resolveSeparator(number, sep, language, region, currency)
+	}
+
+	switch decimal {
+	case ".":
+		number = strings.ReplaceAll(number, ",", "")
+	case ",":
+		number = strings.ReplaceAll(number, ".", "")
+		number = strings.ReplaceAll(number, ",", ".")
+	default:
+		number = strings.NewReplacer(".", "", ",", "").Replace(number)
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+	return amount, true
+}
+
+// resolveSeparator decides whether the only separator kind in a number is the
+// decimal mark (returned) or thousands grouping (empty string)
+func resolveSeparator(number, sep, language, region, currency string) string {
+	if strings.Count(number, sep) > 1 {
+		return ""
+	}
+
+	whole, fraction, _ := strings.Cut(number, sep)
+	if len(fraction) != 3 || whole == "0" {
+		return sep
+	}
+
+	// Exactly three digits after the separator could be either
+	if zeroDecimalCurrencies[currency] {
+		return ""
+	}
+	decimalComma := decimalCommaLanguages[language] && !decimalPointRegions[region]
+	if (sep == ",") == decimalComma {
+		return sep
+	}
+	return ""
+}
+
+// minMax orders two amounts
+func minMax(a, b float64) (float64, float64) {
+	if a > b {
+		return b, a
+	}
+	return a, b
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+// recordPriceObservation stores the price seen for an item during a crawl.
+// scraped holds the values extracted from the page, which may differ from the
+// stored item. Items without a price or availability, like articles, are skipped.
+func recordPriceObservation(itemID uint, scraped models.ScrapedItem, jobID string) {
+	availability := metadataString(scraped.Metadata, "availability")
+	if scraped.Price <= 0 && availability == "" {
+		return
+	}
+
+	observation := models.PriceObservation{
+		ItemID:       itemID,
+		JobID:        jobID,
+		Price:        scraped.Price,
+		Currency:     scraped.Currency,
+		Availability: availability,
+		ObservedAt:   scraped.ScrapedAt,
+	}
+	if observation.ObservedAt.IsZero() {
+		observation.ObservedAt = time.Now()
+	}
+
+	if err := db.DB.Create(&observation).Error; err != nil {
+		log.Printf("Error recording price for item %d: %v", itemID, err)
+	}
+}
+
+// metadataString reads a string value from an item's metadata JSON
+func metadataString(metadataJSON, key string) string {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return ""
+	}
+	value, _ := metadata[key].(string)
+	return value
+}
+
+// GetPriceHistory returns an item's price observations in chronological order,
+// optionally limited to a time window. Zero times leave that end open.
+func GetPriceHistory(itemID uint, since, until time.Time) ([]models.PriceObservation, error) {
+	query := db.DB.Where("item_id = ?", itemID)
+	if !since.IsZero() {
+		query = query.Where("observed_at >= ?", since)
+	}
+	if !until.IsZero() {
+		query = query.Where("observed_at <= ?", until)
+	}
+
+	var observations []models.PriceObservation
+	if err := query.Order("observed_at ASC").Find(&observations).Error; err != nil {
+		return nil, err
+	}
+	return observations, nil
+}
@@ -0,0 +1,41 @@
+package services
+
+import "testing"
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		text     string
+		locale   string
+		amount   float64
+		max      float64
+		was      float64
+		currency string
+	}{
+		{"$1,299.00", "", 1299, 0, 0, "USD"},
+		{"1.299,00 €", "", 1299, 0, 0, "EUR"},
+		{"1 299,00 €", "fr-FR", 1299, 0, 0, "EUR"},
+		{"CHF 45", "", 45, 0, 0, "CHF"},
+		{"CHF 1'250.50", "de-CH", 1250.5, 0, 0, "CHF"},
+		{"¥3,000", "", 3000, 0, 0, "JPY"},
+		{"¥3,000", "zh-CN", 3000, 0, 0, "CNY"},
+		{"from $9.99", "", 9.99, 0, 0, "USD"},
+		{"$10 - $20", "", 10, 20, 0, "USD"},
+		{"€9,99–€19,99", "de", 9.99, 19.99, 0, "EUR"},
+		{"Was $20.00 Now $15.00", "", 15, 0, 20, "USD"},
+		{"$20.00$15.00", "", 15, 0, 20, "USD"},
+		{"1.299 kr", "da-DK", 1299, 0, 0, "DKK"},
+		{"1.299", "en-US", 1.299, 0, 0, ""},
+		{"C$ 12.50", "", 12.5, 0, 0, "CAD"},
+		{"$12.50", "en-CA", 12.5, 0, 0, "CAD"},
+		{"Call for price", "", 0, 0, 0, ""},
+		{"", "", 0, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		got := parsePrice(tt.text, tt.locale)
+		if got.Amount != tt.amount || got.MaxAmount != tt.max || got.WasAmount != tt.was || got.Currency != tt.currency {
+			t.Errorf("parsePrice(%q, %q) = %+v, expected amount %v, max %v, was %v, currency %q",
+				tt.text, tt.locale, got, tt.amount, tt.max, tt.was, tt.currency)
+		}
+	}
+}
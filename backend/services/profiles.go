@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FieldSelector locates a value on the page. The text of the first element
+// matching Selector is used, or its Attr attribute when Attr is set.
+type FieldSelector struct {
+	Selector string `json:"selector" yaml:"selector"`
+	Attr     string `json:"attr,omitempty" yaml:"attr,omitempty"`
+}
+
+// FieldRule is an ordered list of selectors; later entries are fallbacks
+type FieldRule []FieldSelector
+
+// ExtractionProfile maps pages on a site to the selectors used to extract them
+type ExtractionProfile struct {
+	Name        string               `json:"name" yaml:"name"`
+	Domains     []string             `json:"domains" yaml:"domains"`         // Exact hosts, or "*.example.com" for a domain and its subdomains
+	URLPattern  string               `json:"url_pattern" yaml:"url_pattern"` // Optional regular expression the full URL must match
+	Title       FieldRule            `json:"title" yaml:"title"`
+	Description FieldRule            `json:"description" yaml:"description"`
+	Price       FieldRule            `json:"price" yaml:"price"`
+	Image       FieldRule            `json:"image" yaml:"image"`
+	Fields      map[string]FieldRule `json:"fields" yaml:"fields"` // Stored in the item's metadata
+
+	urlPattern *regexp.Regexp
+}
+
+// compile validates the profile and prepares its URL pattern
+func (p *ExtractionProfile) compile() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile has no name")
+	}
+	if len(p.Domains) == 0 && p.URLPattern == "" {
+		return fmt.Errorf("profile %q needs domains or a url_pattern", p.Name)
+	}
+	if len(p.Title) == 0 {
+		return fmt.Errorf("profile %q has no title selectors", p.Name)
+	}
+	if p.URLPattern != "" {
+		re, err := regexp.Compile(p.URLPattern)
+		if err != nil {
+			return fmt.Errorf("profile %q has an invalid url_pattern: %w", p.Name, err)
+		}
+		p.urlPattern = re
+	}
+	return nil
+}
+
+// Matches reports whether the profile applies to the URL
+func (p *ExtractionProfile) Matches(u *url.URL) bool {
+	if len(p.Domains) > 0 && !hostMatchesAny(u.Hostname(), p.Domains) {
+		return false
+	}
+	if p.urlPattern != nil && !p.urlPattern.MatchString(u.String()) {
+		return false
+	}
+	return true
+}
+
+// hostMatchesAny checks a host against exact and "*." wildcard domain patterns
+func hostMatchesAny(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix := strings.TrimPrefix(pattern, "*."); suffix != pattern {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// profileRegistry holds the loaded extraction profiles in load order
+type profileRegistry struct {
+	mu       sync.RWMutex
+	profiles []*ExtractionProfile
+}
+
+var profiles = &profileRegistry{}
+
+// match returns the first profile that applies to the URL, or nil
+func (r *profileRegistry) match(u *url.URL) *ExtractionProfile {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.profiles {
+		if p.Matches(u) {
+			return p
+		}
+	}
+	return nil
+}
+
+// set replaces the loaded profiles
+func (r *profileRegistry) set(loaded []*ExtractionProfile) {
+	r.mu.Lock()
+	r.profiles = loaded
+	r.mu.Unlock()
+}
+
+// LoadExtractionProfiles loads every .yaml, .yml and .json profile in dir,
+// replacing any previously loaded profiles. Files are applied in name order,
+// so the first matching file wins. A missing directory loads no profiles.
+func LoadExtractionProfiles(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		profiles.set(nil)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var loaded []*ExtractionProfile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		profile, err := readExtractionProfile(path)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		if profile == nil {
+			continue
+		}
+		loaded = append(loaded, profile)
+		log.Printf("Loaded extraction profile %q from %s", profile.Name, path)
+	}
+
+	profiles.set(loaded)
+	return len(loaded), nil
+}
+
+// readExtractionProfile parses a single profile file. Files with other
+// extensions are ignored and return a nil profile.
+func readExtractionProfile(path string) (*ExtractionProfile, error) {
+	var unmarshal func([]byte, interface{}) error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".json":
+		unmarshal = json.Unmarshal
+	default:
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile ExtractionProfile
+	if err := unmarshal(data, &profile); err != nil {
+		return nil, err
+	}
+	if err := profile.compile(); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// extractProfileValue returns the first non-empty value produced by the rule
+func extractProfileValue(e *colly.HTMLElement, rule FieldRule) string {
+	for _, sel := range rule {
+		match := e.DOM.Find(sel.Selector).First()
+
+		var value string
+		if sel.Attr != "" {
+			value, _ = match.Attr(sel.Attr)
+		} else {
+			value = match.Text()
+		}
+
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// extractWithProfile builds an item from the page using the profile's
+// selectors. A title, description or price the profile does not find falls
+// back to the default heuristics; returns nil if the page has no title.
+func extractWithProfile(e *colly.HTMLElement, profile *ExtractionProfile) *models.ScrapedItem {
+	title := extractProfileValue(e, profile.Title)
+	description := extractProfileValue(e, profile.Description)
+	if title == "" || description == "" {
+		if fallback := extractGenericContentData(e); fallback != nil {
+			if title == "" {
+				title = fallback.Title
+			}
+			if description == "" {
+				description = fallback.Description
+			}
+		}
+	}
+	if title == "" {
+		return nil
+	}
+
+	imageURL := extractProfileValue(e, profile.Image)
+	if imageURL != "" {
+		imageURL = e.Request.AbsoluteURL(imageURL)
+	}
+
+	priceText := extractProfileValue(e, profile.Price)
+	if priceText == "" {
+		priceText = getFirstNonEmpty(e, priceSelectors...)
+	}
+	price := parsePrice(priceText, pageLanguage(e))
+
+	metadata := map[string]interface{}{
+		"profile": profile.Name,
+		"domain":  e.Request.URL.Hostname(),
+		"path":    e.Request.URL.Path,
+	}
+	for name, rule := range profile.Fields {
+		if value := extractProfileValue(e, rule); value != "" {
+			metadata[name] = value
+		}
+	}
+	price.metadata(metadata)
+	metadataJSON, _ := json.Marshal(metadata)
+
+	item := models.ScrapedItem{
+		Title:       title,
+		Description: description,
+		URL:         e.Request.URL.String(),
+		ImageURL:    imageURL,
+		Price:       price.Amount,
+		Currency:    price.Currency,
+		PriceText:   price.Raw,
+		ScrapedAt:   time.Now(),
+		Metadata:    string(metadataJSON),
+	}
+
+	return &item
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+func TestLoadExtractionProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlProfile := `
+name: shop
+domains: ["*.shop.example"]
+url_pattern: "/products/"
+title:
+  - selector: h1.name
+price:
+  - selector: .cost
+  - selector: meta[itemprop='price']
+    attr: content
+`
+	jsonProfile := `{"name": "news", "domains": ["news.example"], "title": [{"selector": "h1"}]}`
+
+	os.WriteFile(filepath.Join(dir, "a-shop.yaml"), []byte(yamlProfile), 0644)
+	os.WriteFile(filepath.Join(dir, "b-news.json"), []byte(jsonProfile), 0644)
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644)
+
+	count, err := LoadExtractionProfiles(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer profiles.set(nil)
+
+	if count != 2 {
+		t.Fatalf("Expected 2 profiles, got %d", count)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.shop.example/products/42", "shop"},
+		{"https://shop.example/products/42", "shop"},
+		{"https://www.shop.example/about", ""},
+		{"https://news.example/story", "news"},
+		{"https://other.example/products/42", ""},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		profile := profiles.match(u)
+
+		name := ""
+		if profile != nil {
+			name = profile.Name
+		}
+		if name != tt.expected {
+			t.Errorf("Expected %s to match profile %q, got %q", tt.url, tt.expected, name)
+		}
+	}
+}
+
+func TestLoadExtractionProfilesRejectsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("name: bad\ntitle:\n  - selector: h1\n"), 0644)
+
+	if _, err := LoadExtractionProfiles(dir); err == nil {
+		t.Error("Expected a profile without domains or url_pattern to be rejected")
+	}
+}
+
+func TestExtractProfileValue(t *testing.T) {
+	html := `
+		<html>
+			<body>
+				<h1 class="name">First Name</h1>
+				<h1 class="name">Second Name</h1>
+				<meta itemprop="price" content="12.50" />
+			</body>
+		</html>
+	`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+
+	c := colly.NewCollector()
+	var title, price string
+
+	c.OnHTML("body", func(e *colly.HTMLElement) {
+		title = extractProfileValue(e, FieldRule{{Selector: "h1.name"}})
+		price = extractProfileValue(e, FieldRule{
+			{Selector: ".cost"},
+			{Selector: "meta[itemprop='price']", Attr: "content"},
+		})
+	})
+
+	c.Visit(ts.URL)
+
+	if title != "First Name" {
+		t.Errorf("Expected title to be 'First Name', got '%s'", title)
+	}
+
+	if price != "12.50" {
+		t.Errorf("Expected fallback price '12.50', got '%s'", price)
+	}
+}
+
+func TestExtractWithProfileFallsBack(t *testing.T) {
+	html := `
+		<html>
+			<head><meta name="description" content="A compact stove" /></head>
+			<body>
+				<h1>Camp Stove</h1>
+				<span class="price">$49.99 <s>Was $59.99</s></span>
+				<span class="sku">ST-1</span>
+			</body>
+		</html>
+	`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(html))
+	}))
+	defer ts.Close()
+
+	// None of the profile's title or price selectors exist on the page
+	profile := &ExtractionProfile{
+		Name:   "shop",
+		Title:  FieldRule{{Selector: "h1.name"}},
+		Price:  FieldRule{{Selector: ".cost"}},
+		Fields: map[string]FieldRule{"sku": {{Selector: ".sku"}}},
+	}
+
+	c := colly.NewCollector()
+	var item *models.ScrapedItem
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		item = extractWithProfile(e, profile)
+	})
+	c.Visit(ts.URL)
+
+	if item == nil {
+		t.Fatal("Expected the default heuristics to find the title")
+	}
+	if item.Title != "Camp Stove" || item.Description != "A compact stove" {
+		t.Errorf("Expected the heuristic title and description, got %q and %q", item.Title, item.Description)
+	}
+	if item.Price != 49.99 {
+		t.Errorf("Expected the heuristic price 49.99, got %v", item.Price)
+	}
+
+	var metadata map[string]interface{}
+	json.Unmarshal([]byte(item.Metadata), &metadata)
+	if metadata["sku"] != "ST-1" || metadata["wasPrice"] != 59.99 {
+		t.Errorf("Expected the sku field and a numeric wasPrice, got %v", metadata)
+	}
+}
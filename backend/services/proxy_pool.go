@@ -0,0 +1,386 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Proxy selection strategies
+const (
+	ProxyRoundRobin = "round_robin" // Rotate through the proxies on every request
+	ProxySticky     = "sticky"      // Keep each host on the same proxy while it is healthy
+)
+
+// proxyHeader carries the proxy picked in OnRequest to the transport's proxy
+// function, which removes it before the request is written
+const proxyHeader = "X-Scrape-N-Serve-Proxy"
+
+// proxyCtx remembers which proxy a request went through
+const proxyCtx = "proxy"
+
+// redactedProxyUser replaces the credentials of proxy URLs in stats, logs
+// and stored job options
+const redactedProxyUser = "xxxxx"
+
+// ProxyStats reports how a proxy has behaved across all crawls
+type ProxyStats struct {
+	URL          string     `json:"url"` // Without credentials
+	Requests     int        `json:"requests"`
+	Successes    int        `json:"successes"`
+	Failures     int        `json:"failures"`
+	Evictions    int        `json:"evictions"`
+	EvictedUntil *time.Time `json:"evicted_until,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// proxyState is the shared health of one proxy
+type proxyState struct {
+	stats       ProxyStats
+	consecutive int // Failures since the last success
+	until       time.Time
+}
+
+// proxyTracker keeps proxy health keyed by proxy URL, so every job using a
+// proxy sees its evictions
+type proxyTracker struct {
+	mu     sync.Mutex
+	states map[string]*proxyState
+	now    func() time.Time
+}
+
+var proxyHealth = newProxyTracker()
+
+// defaultProxies are used by jobs that do not bring their own
+var defaultProxies struct {
+	mu         sync.RWMutex
+	urls       []string
+	selection  string
+	unregister func() // Forgets the passwords of urls in log output
+}
+
+// newProxyTracker creates a tracker with no proxies seen yet
+func newProxyTracker() *proxyTracker {
+	return &proxyTracker{
+		states: make(map[string]*proxyState),
+		now:    time.Now,
+	}
+}
+
+// state returns the health of a proxy, creating it on first use. Callers must hold t.mu.
+func (t *proxyTracker) state(proxy *url.URL) *proxyState {
+	key := proxy.String()
+	s, ok := t.states[key]
+	if !ok {
+		s = &proxyState{stats: ProxyStats{URL: redactProxyURL(proxy)}}
+		t.states[key] = s
+	}
+	return s
+}
+
+// available reports whether a proxy is not evicted. Callers must hold t.mu.
+func (t *proxyTracker) available(proxy *url.URL) bool {
+	return !t.state(proxy).until.After(t.now())
+}
+
+// succeeded records a request the proxy carried through
+func (t *proxyTracker) succeeded(proxy *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.state(proxy)
+	s.stats.Successes++
+	s.consecutive = 0
+}
+
+// failed records a proxy failure and evicts the proxy for cooldown once it
+// has failed maxFailures times in a row. Returns true if it was evicted.
+func (t *proxyTracker) failed(proxy *url.URL, err error, maxFailures int, cooldown time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.state(proxy)
+	s.stats.Failures++
+	s.stats.LastError = err.Error()
+	s.consecutive++
+	if s.consecutive < maxFailures {
+		return false
+	}
+
+	s.consecutive = 0
+	s.until = t.now().Add(cooldown)
+	s.stats.Evictions++
+	return true
+}
+
+// stats returns a snapshot of every proxy's stats, ordered by URL
+func (t *proxyTracker) stats() []ProxyStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	list := make([]ProxyStats, 0, len(t.states))
+	for _, s := range t.states {
+		stats := s.stats
+		if s.until.After(now) {
+			until := s.until
+			stats.EvictedUntil = &until
+		}
+		list = append(list, stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+	return list
+}
+
+// proxyPool picks the proxy for each request of a crawl
+type proxyPool struct {
+	proxies     []*url.URL
+	selection   string
+	maxFailures int
+	cooldown    time.Duration
+	tracker     *proxyTracker
+
+	mu     sync.Mutex
+	next   int
+	sticky map[string]int // Host -> index into proxies
+}
+
+// newProxyPool parses the proxy URLs of a crawl. Returns nil without proxies.
+func newProxyPool(config ScraperConfig, tracker *proxyTracker) (*proxyPool, error) {
+	if len(config.Proxies) == 0 {
+		return nil, nil
+	}
+
+	proxies, err := parseProxyURLs(config.Proxies)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProxySelection(config.ProxySelection); err != nil {
+		return nil, err
+	}
+
+	return &proxyPool{
+		proxies:     proxies,
+		selection:   config.ProxySelection,
+		maxFailures: config.ProxyMaxFailures,
+		cooldown:    config.ProxyCooldown,
+		tracker:     tracker,
+		sticky:      make(map[string]int),
+	}, nil
+}
+
+// parseProxyURLs validates http, https and socks5 proxy URLs
+func parseProxyURLs(raw []string) ([]*url.URL, error) {
+	proxies := make([]*url.URL, 0, len(raw))
+	for _, r := range raw {
+		u, err := url.Parse(strings.TrimSpace(r))
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", r, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", r)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q: missing host", r)
+		}
+		proxies = append(proxies, u)
+	}
+	return proxies, nil
+}
+
+// validateProxySelection checks a selection strategy; empty means round robin
+func validateProxySelection(selection string) error {
+	switch selection {
+	case "", ProxyRoundRobin, ProxySticky:
+		return nil
+	}
+	return fmt.Errorf("invalid proxy selection %q: use %q or %q", selection, ProxyRoundRobin, ProxySticky)
+}
+
+// redactProxyURL hides proxy credentials in stats and logs
+func redactProxyURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	redacted := *u
+	redacted.User = url.User(redactedProxyUser)
+	return redacted.String()
+}
+
+// hasRedactedProxy reports whether a proxy URL lost its credentials to redactProxyURL
+func hasRedactedProxy(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.User != nil && u.User.String() == redactedProxyUser
+}
+
+// proxyPassword returns the password of a proxy URL, or ""
+func proxyPassword(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.User == nil {
+		return ""
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// pick returns the proxy for a request to host. Evicted proxies are skipped;
+// when all of them are evicted, the one returning first is used.
+func (p *proxyPool) pick(host string) *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tracker.mu.Lock()
+	defer p.tracker.mu.Unlock()
+
+	index := -1
+	if p.selection == ProxySticky {
+		if i, ok := p.sticky[host]; ok && p.tracker.available(p.proxies[i]) {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		for n := 0; n < len(p.proxies); n++ {
+			i := (p.next + n) % len(p.proxies)
+			if p.tracker.available(p.proxies[i]) {
+				index = i
+				p.next = i + 1
+				break
+			}
+		}
+	}
+
+	if index < 0 {
+		index = 0
+		for i, proxy := range p.proxies {
+			if p.tracker.state(proxy).until.Before(p.tracker.state(p.proxies[index]).until) {
+				index = i
+			}
+		}
+	}
+
+	if p.selection == ProxySticky {
+		p.sticky[host] = index
+	}
+
+	proxy := p.proxies[index]
+	p.tracker.state(proxy).stats.Requests++
+	return proxy
+}
+
+// assign picks a proxy for the request and hands it to the transport. A nil
+// pool leaves the request direct.
+func (p *proxyPool) assign(r *colly.Request) {
+	if p == nil {
+		return
+	}
+	proxy := p.pick(r.URL.Host)
+	r.Ctx.Put(proxyCtx, proxy.String())
+	r.Headers.Set(proxyHeader, proxy.String())
+}
+
+// succeeded credits the request's proxy with a completed request
+func (p *proxyPool) succeeded(r *colly.Request) {
+	if proxy := p.used(r); proxy != nil {
+		p.tracker.succeeded(proxy)
+	}
+}
+
+// failed blames the request's proxy when the error points at it, and
+// returns the proxy if that got it evicted
+func (p *proxyPool) failed(r *colly.Request, status int, err error) *url.URL {
+	proxy := p.used(r)
+	if proxy == nil {
+		return nil
+	}
+	if !isProxyFailure(status, err) {
+		// The proxy delivered the target's answer
+		p.tracker.succeeded(proxy)
+		return nil
+	}
+	if p.tracker.failed(proxy, err, p.maxFailures, p.cooldown) {
+		return proxy
+	}
+	return nil
+}
+
+// proxyFunc is the transport's proxy function. It uses the proxy assigned in
+// OnRequest, and picks one for requests colly makes on its own, like redirects.
+func (p *proxyPool) proxyFunc(req *http.Request) (*url.URL, error) {
+	if assigned := req.Header.Get(proxyHeader); assigned != "" {
+		req.Header.Del(proxyHeader)
+		return url.Parse(assigned)
+	}
+	return p.pick(req.URL.Host), nil
+}
+
+// used returns the proxy a request was assigned, or nil
+func (p *proxyPool) used(r *colly.Request) *url.URL {
+	if p == nil {
+		return nil
+	}
+	assigned := r.Ctx.Get(proxyCtx)
+	if assigned == "" {
+		return nil
+	}
+	u, err := url.Parse(assigned)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// isProxyFailure reports whether an error points at the proxy rather than
+// the target site: no response at all, or the proxy refusing us
+func isProxyFailure(status int, err error) bool {
+	if status == http.StatusProxyAuthRequired {
+		return true
+	}
+	return status == 0 && err != nil && !errors.Is(err, colly.ErrAbortedAfterHeaders)
+}
+
+// SetDefaultProxies configures the proxies used by jobs that do not list
+// their own. selection is ProxyRoundRobin (the default) or ProxySticky.
+func SetDefaultProxies(urls []string, selection string) error {
+	if _, err := parseProxyURLs(urls); err != nil {
+		return err
+	}
+	if err := validateProxySelection(selection); err != nil {
+		return err
+	}
+	// The default proxies serve every job, so their passwords stay redacted
+	// until they are replaced
+	unregister := registerSessionSecrets(ScrapeOptions{Proxies: urls})
+
+	defaultProxies.mu.Lock()
+	previous := defaultProxies.unregister
+	defaultProxies.urls = urls
+	defaultProxies.selection = selection
+	defaultProxies.unregister = unregister
+	defaultProxies.mu.Unlock()
+
+	if previous != nil {
+		previous()
+	}
+	return nil
+}
+
+// GetProxyStats returns the health and usage of every proxy used so far
+func GetProxyStats() []ProxyStats {
+	return proxyHealth.stats()
+}
+
+// defaultProxyConfig returns the configured default proxies and selection
+func defaultProxyConfig() ([]string, string) {
+	defaultProxies.mu.RLock()
+	defer defaultProxies.mu.RUnlock()
+	return defaultProxies.urls, defaultProxies.selection
+}
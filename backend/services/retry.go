@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// attemptCtx counts the fetches made for a request; colly keeps the context across retries
+const attemptCtx = "attempt"
+
+// DefaultRetryStatusCodes are the HTTP statuses retried by default
+var DefaultRetryStatusCodes = []int{408, 425, 429, 500, 502, 503, 504}
+
+// requestAttempt returns which fetch of the request this is, starting at 1
+func requestAttempt(r *colly.Request) int {
+	if attempt, ok := r.Ctx.GetAny(attemptCtx).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// shouldRetry reports whether a failed fetch is worth another attempt under
+// the config's retry policy. status is 0 when no response was received.
+func shouldRetry(config ScraperConfig, attempt, status int, err error) bool {
+	if attempt >= config.MaxAttempts {
+		return false
+	}
+
+	if status == 0 {
+		return config.RetryNetworkErrors && isNetworkError(err)
+	}
+	for _, code := range config.RetryStatusCodes {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetworkError reports whether err comes from the transport, like a
+// timeout, refused connection or a response cut short. The *url.Error every
+// client error is wrapped in is looked through, as it is a net.Error itself.
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// retryDelay returns the wait before the given attempt: RetryBackoff before
+// the second, doubling for every attempt after that
+func retryDelay(config ScraperConfig, attempt int) time.Duration {
+	delay := config.RetryBackoff
+	for i := 2; i < attempt; i++ {
+		delay *= 2
+	}
+	return delay
+}
+
+// retryRequest waits out the backoff and fetches the request again. It gives
+// up without retrying when done is closed first.
+func retryRequest(config ScraperConfig, r *colly.Request, done <-chan struct{}) error {
+	attempt := requestAttempt(r) + 1
+	r.Ctx.Put(attemptCtx, attempt)
+
+	if delay := retryDelay(config, attempt); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-done:
+			return errors.New("job stopped")
+		}
+	}
+	return r.Retry()
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestShouldRetry(t *testing.T) {
+	config := DefaultScraperConfig()
+	timeout := &net.OpError{Op: "dial", Err: errors.New("i/o timeout")}
+
+	tests := []struct {
+		name    string
+		attempt int
+		status  int
+		err     error
+		want    bool
+	}{
+		{"server error", 1, 503, errors.New("Service Unavailable"), true},
+		{"rate limited", 2, 429, errors.New("Too Many Requests"), true},
+		{"last attempt", 3, 503, errors.New("Service Unavailable"), false},
+		{"not found", 1, 404, errors.New("Not Found"), false},
+		{"network error", 1, 0, timeout, true},
+		{"truncated body", 1, 0, fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"other error", 1, 0, errors.New("unsupported scheme"), false},
+	}
+
+	for _, tt := range tests {
+		if got := shouldRetry(config, tt.attempt, tt.status, tt.err); got != tt.want {
+			t.Errorf("%s: shouldRetry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	config.RetryNetworkErrors = false
+	if shouldRetry(config, 1, 0, timeout) {
+		t.Error("Expected network errors not to be retried when disabled")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	config := DefaultScraperConfig()
+	config.RetryBackoff = time.Second
+
+	for attempt, want := range map[int]time.Duration{2: time.Second, 3: 2 * time.Second, 4: 4 * time.Second} {
+		if got := retryDelay(config, attempt); got != want {
+			t.Errorf("retryDelay(attempt %d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestRetryFailedURLs(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	brokenFixed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		flakyHits, fixed := hits["/flaky"], brokenFixed
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/robots.txt":
+			http.NotFound(w, r)
+			return
+		case r.URL.Path == "/flaky" && flakyHits == 1:
+			http.Error(w, "try again", http.StatusInternalServerError)
+			return
+		case r.URL.Path == "/broken" && !fixed:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Page %s</h1><a href="/flaky">Flaky</a><a href="/broken">Broken</a></body></html>`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{URL: ts.URL + "/"})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.Retries != 1 {
+		t.Errorf("Expected the flaky page to be retried once, got %d retries", job.Retries)
+	}
+	if job.FailedURLs != 1 {
+		t.Fatalf("Expected one failed URL, got %d", job.FailedURLs)
+	}
+
+	failed, _ := GetFailedURLs(jobID)
+	if len(failed) != 1 || failed[0].FetchURL != ts.URL+"/broken" || failed[0].StatusCode != 404 {
+		t.Fatalf("Expected /broken to be the failed URL, got %+v", failed)
+	}
+
+	mu.Lock()
+	brokenFixed = true
+	mu.Unlock()
+
+	if requeued, err := RetryFailedURLs(jobID); err != nil || requeued != 1 {
+		t.Fatalf("Expected one requeued URL, got %d (%v)", requeued, err)
+	}
+	job = waitForJob(t, jobID)
+
+	if job.State != models.JobStateSucceeded || job.FailedURLs != 0 {
+		t.Errorf("Expected the retried job to succeed without failures, got %s with %d failed", job.State, job.FailedURLs)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/"] != 1 || hits["/flaky"] != 2 || hits["/broken"] != 2 {
+		t.Errorf("Expected only the failed page to be fetched again, got %v", hits)
+	}
+	if _, err := RetryFailedURLs(jobID); err != ErrNoFailedURLs {
+		t.Errorf("Expected ErrNoFailedURLs once nothing failed, got %v", err)
+	}
+}
+
+func TestCancelDuringRetryBackoffLeavesPagePending(t *testing.T) {
+	setupItemStoreDB(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{URL: ts.URL + "/", IgnoreRobotsTxt: true})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+
+	// The first retry waits a second; cancel while it does
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if job, _ := GetJob(jobID); job.Retries > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Job did not retry in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := CancelJob(jobID); err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.State != models.JobStateCancelled || job.FailedURLs != 0 {
+		t.Errorf("Expected a cancelled job without failed URLs, got %s with %d failed", job.State, job.FailedURLs)
+	}
+	if pending, _ := pendingFrontierCount(jobID); pending != 1 {
+		t.Errorf("Expected the page to stay pending for a resume, got %d pending", pending)
+	}
+}
+
+// waitForJob polls until the job is no longer queued or running
+func waitForJob(t *testing.T, id string) models.ScrapeJob {
+	deadline := time.Now().Add(15 * time.Second)
+	for {
+		job, err := GetJob(id)
+		if err == nil && !job.IsActive() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s did not finish in time", id)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
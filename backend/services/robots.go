@@ -0,0 +1,141 @@
+package services
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// robotsCacheTTL is how long a fetched robots.txt is trusted before refetching
+const robotsCacheTTL = time.Hour
+
+// robotsEntry is a cached robots.txt for a single host
+type robotsEntry struct {
+	data      *robotstxt.RobotsData
+	fetchedAt time.Time
+}
+
+// robotsCache fetches and caches robots.txt per scheme and host. The rules
+// are shared by all jobs; each lookup may bring the client of its job, so a
+// missing file is fetched through the job's proxies.
+type robotsCache struct {
+	mu       sync.Mutex
+	entries  map[string]robotsEntry
+	fetching map[string]chan struct{} // Closed when the host's fetch in flight is done
+	client   *http.Client             // Used for lookups without a client of their own
+	ttl      time.Duration
+}
+
+var robots = newRobotsCache(&http.Client{Timeout: 10 * time.Second}, robotsCacheTTL)
+
+// newRobotsCache creates an empty robots.txt cache
+func newRobotsCache(client *http.Client, ttl time.Duration) *robotsCache {
+	return &robotsCache{
+		entries:  make(map[string]robotsEntry),
+		fetching: make(map[string]chan struct{}),
+		client:   client,
+		ttl:      ttl,
+	}
+}
+
+// get returns the robots.txt rules for the URL's host, fetching them with
+// client (or the cache's own when nil) if needed. A host whose robots.txt
+// cannot be fetched is treated as allowing everything. Concurrent lookups
+// for a host share a single fetch.
+func (rc *robotsCache) get(client *http.Client, u *url.URL) *robotstxt.RobotsData {
+	key := u.Scheme + "://" + u.Host
+
+	rc.mu.Lock()
+	for {
+		entry, ok := rc.entries[key]
+		if ok && time.Since(entry.fetchedAt) < rc.ttl {
+			rc.mu.Unlock()
+			return entry.data
+		}
+		wait, busy := rc.fetching[key]
+		if !busy {
+			break
+		}
+		rc.mu.Unlock()
+		<-wait
+		rc.mu.Lock()
+	}
+	done := make(chan struct{})
+	rc.fetching[key] = done
+	rc.mu.Unlock()
+
+	if client == nil {
+		client = rc.client
+	}
+	data, err := fetchRobots(client, key+"/robots.txt")
+	if err != nil {
+		log.Printf("Could not fetch robots.txt for %s: %v", key, err)
+	}
+
+	rc.mu.Lock()
+	rc.entries[key] = robotsEntry{data: data, fetchedAt: time.Now()}
+	delete(rc.fetching, key)
+	close(done)
+	rc.mu.Unlock()
+
+	return data
+}
+
+// fetchRobots downloads and parses a robots.txt file
+func fetchRobots(client *http.Client, robotsURL string) (*robotstxt.RobotsData, error) {
+	resp, err := client.Get(robotsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return robotstxt.FromResponse(resp)
+}
+
+// group returns the robots.txt group that applies to the user agent, if any
+func (rc *robotsCache) group(client *http.Client, u *url.URL, userAgent string) *robotstxt.Group {
+	data := rc.get(client, u)
+	if data == nil {
+		return nil
+	}
+	return data.FindGroup(userAgent)
+}
+
+// allowed reports whether the user agent may fetch the URL
+func (rc *robotsCache) allowed(client *http.Client, u *url.URL, userAgent string) bool {
+	group := rc.group(client, u, userAgent)
+	if group == nil {
+		return true
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return group.Test(path)
+}
+
+// crawlDelay returns the Crawl-delay requested for the user agent on the URL's host
+func (rc *robotsCache) crawlDelay(client *http.Client, u *url.URL, userAgent string) time.Duration {
+	group := rc.group(client, u, userAgent)
+	if group == nil {
+		return 0
+	}
+	return group.CrawlDelay
+}
+
+// sitemaps returns the Sitemap URLs listed in the host's robots.txt
+func (rc *robotsCache) sitemaps(client *http.Client, u *url.URL) []string {
+	data := rc.get(client, u)
+	if data == nil {
+		return nil
+	}
+	return data.Sitemaps
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsCache(t *testing.T) {
+	var fetches int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("User-agent: ScrapeNServe\nDisallow: /private/\nCrawl-delay: 2\n\nUser-agent: *\nDisallow: /\n"))
+	}))
+	defer ts.Close()
+
+	rc := newRobotsCache(ts.Client(), time.Hour)
+	base, _ := url.Parse(ts.URL)
+
+	allowedURL, _ := base.Parse("/products/1")
+	if !rc.allowed(nil, allowedURL, DefaultUserAgent) {
+		t.Errorf("Expected %s to be allowed", allowedURL)
+	}
+
+	blockedURL, _ := base.Parse("/private/account")
+	if rc.allowed(nil, blockedURL, DefaultUserAgent) {
+		t.Errorf("Expected %s to be disallowed", blockedURL)
+	}
+
+	// Other agents fall under the catch-all group
+	if rc.allowed(nil, allowedURL, "SomeOtherBot") {
+		t.Errorf("Expected %s to be disallowed for other agents", allowedURL)
+	}
+
+	if delay := rc.crawlDelay(nil, base, DefaultUserAgent); delay != 2*time.Second {
+		t.Errorf("Expected crawl delay of 2s, got %v", delay)
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", n)
+	}
+}
+
+func TestRobotsCacheMissingFile(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	rc := newRobotsCache(ts.Client(), time.Hour)
+	u, _ := url.Parse(ts.URL + "/anything")
+
+	if !rc.allowed(nil, u, DefaultUserAgent) {
+		t.Error("Expected everything to be allowed when robots.txt is missing")
+	}
+}
+
+func TestCrawlDelayAppliesToEveryHost(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	var visits []time.Time
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nCrawl-delay: 2\n"))
+			return
+		}
+		mu.Lock()
+		visits = append(visits, time.Now())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Page %s</h1><a href="/b">B</a></body></html>`, r.URL.Path)
+	}))
+	defer other.Close()
+
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Start</h1><a href="%s/a">A</a></body></html>`, other.URL)
+	}))
+	defer start.Close()
+
+	// Both test servers listen on 127.0.0.1, so the other one is in scope
+	jobID, err := StartScraping(ScrapeOptions{URL: start.URL + "/", MaxDepth: 3})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	waitForJob(t, jobID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(visits) != 2 {
+		t.Fatalf("Expected both pages of the second host to be fetched, got %d", len(visits))
+	}
+	if gap := visits[1].Sub(visits[0]); gap < 1900*time.Millisecond {
+		t.Errorf("Expected the second host's 2s Crawl-delay to be honored, got %v between requests", gap)
+	}
+}
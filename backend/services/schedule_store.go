@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSchedule is returned when a schedule fails validation
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// validateSchedule checks the schedule fields and computes its next run time
+func validateSchedule(schedule *models.Schedule, now time.Time) error {
+	parsedURL, err := url.Parse(schedule.URL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return fmt.Errorf("%w: URL must be absolute", ErrInvalidSchedule)
+	}
+	if schedule.CronExpr != "" && schedule.IntervalMinutes > 0 {
+		return fmt.Errorf("%w: set either cron_expr or interval_minutes, not both", ErrInvalidSchedule)
+	}
+	if schedule.IntervalMinutes < 0 {
+		return fmt.Errorf("%w: interval_minutes must not be negative", ErrInvalidSchedule)
+	}
+	if schedule.CredentialID != 0 {
+		if _, err := GetCredential(schedule.CredentialID); err != nil {
+			return fmt.Errorf("%w: credential %d does not exist", ErrInvalidSchedule, schedule.CredentialID)
+		}
+	}
+
+	next, err := nextRunTime(*schedule, now, defaultScheduleInterval())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	schedule.NextRunAt = &next
+	return nil
+}
+
+// CreateSchedule validates and stores a new schedule
+func CreateSchedule(schedule *models.Schedule) error {
+	if err := validateSchedule(schedule, time.Now()); err != nil {
+		return err
+	}
+	return db.DB.Create(schedule).Error
+}
+
+// UpdateSchedule validates and saves changes to an existing schedule.
+// The next run time is recomputed from the new timing.
+func UpdateSchedule(schedule *models.Schedule) error {
+	if err := validateSchedule(schedule, time.Now()); err != nil {
+		return err
+	}
+	return db.DB.Save(schedule).Error
+}
+
+// GetSchedule loads a schedule by ID
+func GetSchedule(id uint) (models.Schedule, error) {
+	var schedule models.Schedule
+	err := db.DB.First(&schedule, id).Error
+	return schedule, err
+}
+
+// ListSchedules returns all schedules ordered by ID
+func ListSchedules() ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	err := db.DB.Order("id ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+// DeleteSchedule removes a schedule. Jobs it already started keep running.
+func DeleteSchedule(id uint) error {
+	result := db.DB.Delete(&models.Schedule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/db"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/robfig/cron/v3"
+)
+
+// DefaultSchedulerTick is how often the scheduler looks for due schedules
+const DefaultSchedulerTick = 30 * time.Second
+
+// Scheduler fires scraping jobs for schedules that are due
+type Scheduler struct {
+	defaultInterval time.Duration
+	tick            time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// NewScheduler creates a scheduler. defaultInterval applies to schedules that
+// specify neither a cron expression nor an interval.
+func NewScheduler(defaultInterval, tick time.Duration) *Scheduler {
+	if tick <= 0 {
+		tick = DefaultSchedulerTick
+	}
+	return &Scheduler{
+		defaultInterval: defaultInterval,
+		tick:            tick,
+		stop:            make(chan struct{}),
+	}
+}
+
+var (
+	scheduler   *Scheduler
+	schedulerMu sync.Mutex
+)
+
+// StartScheduler starts the global scheduler in the background
+func StartScheduler(defaultInterval time.Duration) {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+
+	if scheduler != nil {
+		return
+	}
+	scheduler = NewScheduler(defaultInterval, DefaultSchedulerTick)
+	go scheduler.Run()
+}
+
+// defaultScheduleInterval returns the interval used by schedules without their own timing
+func defaultScheduleInterval() time.Duration {
+	schedulerMu.Lock()
+	defer schedulerMu.Unlock()
+
+	if scheduler != nil && scheduler.defaultInterval > 0 {
+		return scheduler.defaultInterval
+	}
+	return 60 * time.Minute
+}
+
+// Run checks for due schedules until Stop is called
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	s.runDue(time.Now())
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.runDue(now)
+		}
+	}
+}
+
+// Stop ends the scheduler loop
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// runDue starts a job for every enabled schedule whose next run has passed
+func (s *Scheduler) runDue(now time.Time) {
+	var due []models.Schedule
+	err := db.DB.Where("enabled = ? AND next_run_at <= ?", true, now).Find(&due).Error
+	if err != nil {
+		log.Printf("Scheduler failed to load schedules: %v", err)
+		return
+	}
+
+	for i := range due {
+		s.fire(&due[i], now)
+	}
+}
+
+// fire starts a job for the schedule unless its previous job is still active
+func (s *Scheduler) fire(schedule *models.Schedule, now time.Time) {
+	updates := map[string]interface{}{}
+
+	if job, ok := jobManager.Get(schedule.LastJobID); ok && job.IsActive() {
+		// Never let runs of the same schedule overlap
+		log.Printf("Skipping run of schedule %d: job %s is still active", schedule.ID, job.ID)
+		updates["skipped_runs"] = schedule.SkippedRuns + 1
+	} else {
+		jobID, err := StartScraping(ScrapeOptions{
+			URL:          schedule.URL,
+			MaxDepth:     schedule.MaxDepth,
+			Upsert:       schedule.Upsert,
+			CredentialID: schedule.CredentialID,
+		})
+		if err != nil {
+			log.Printf("Schedule %d failed to start scraping: %v", schedule.ID, err)
+		} else {
+			log.Printf("Schedule %d started job %s", schedule.ID, jobID)
+			updates["last_job_id"] = jobID
+			updates["last_run_at"] = now
+		}
+	}
+
+	next, err := nextRunTime(*schedule, now, s.defaultInterval)
+	if err != nil {
+		// A broken expression would otherwise fire on every tick
+		log.Printf("Disabling schedule %d: %v", schedule.ID, err)
+		updates["enabled"] = false
+	} else {
+		updates["next_run_at"] = next
+	}
+
+	if err := db.DB.Model(schedule).Updates(updates).Error; err != nil {
+		log.Printf("Error updating schedule %d: %v", schedule.ID, err)
+	}
+}
+
+// nextRunTime computes when a schedule should fire next after the given time
+func nextRunTime(schedule models.Schedule, from time.Time, defaultInterval time.Duration) (time.Time, error) {
+	if schedule.CronExpr != "" {
+		expr, err := cron.ParseStandard(schedule.CronExpr)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", schedule.CronExpr, err)
+		}
+		return expr.Next(from), nil
+	}
+
+	interval := time.Duration(schedule.IntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if interval <= 0 {
+		return time.Time{}, fmt.Errorf("schedule has no interval")
+	}
+	return from.Add(interval), nil
+}
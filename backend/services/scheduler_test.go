@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestNextRunTime(t *testing.T) {
+	from := time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule models.Schedule
+		expected time.Time
+	}{
+		{
+			name:     "cron expression",
+			schedule: models.Schedule{CronExpr: "0 * * * *"},
+			expected: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "interval",
+			schedule: models.Schedule{IntervalMinutes: 30},
+			expected: from.Add(30 * time.Minute),
+		},
+		{
+			name:     "default interval",
+			schedule: models.Schedule{},
+			expected: from.Add(time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, err := nextRunTime(tt.schedule, from, time.Hour)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !next.Equal(tt.expected) {
+				t.Errorf("Expected next run at %v, got %v", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := time.Now()
+
+	valid := models.Schedule{URL: "https://example.com", CronExpr: "*/15 * * * *"}
+	if err := validateSchedule(&valid, now); err != nil {
+		t.Fatalf("Expected schedule to be valid, got %v", err)
+	}
+	if valid.NextRunAt == nil || !valid.NextRunAt.After(now) {
+		t.Errorf("Expected next run to be set in the future, got %v", valid.NextRunAt)
+	}
+
+	invalid := []models.Schedule{
+		{URL: "not a url"},
+		{URL: "https://example.com", CronExpr: "every tuesday"},
+		{URL: "https://example.com", CronExpr: "0 * * * *", IntervalMinutes: 5},
+		{URL: "https://example.com", IntervalMinutes: -1},
+	}
+	for _, schedule := range invalid {
+		if err := validateSchedule(&schedule, now); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Expected ErrInvalidSchedule for %+v, got %v", schedule, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Crawl scope modes
+const (
+	ScopeSameHost   = "same_host"   // Only the start URL's host
+	ScopeSameDomain = "same_domain" // Any host under the start URL's registered domain, e.g. shop. and www.example.com
+	ScopeSamePrefix = "same_prefix" // Only the start URL's host, under the start URL's path
+)
+
+// errOutOfScope rejects redirects leaving the crawl's scope
+var errOutOfScope = errors.New("out of the crawl's scope")
+
+// regexPatternPrefix marks an include/exclude pattern as a regular expression
+const regexPatternPrefix = "re:"
+
+// crawlScope decides which URLs a job may fetch. The same rules apply to
+// every link-following callback, sitemap seeds and redirects.
+type crawlScope struct {
+	mode     string
+	startKey string // Canonical start URL, always in scope
+	host     string
+	domain   string   // Registered domain, for ScopeSameDomain
+	prefix   string   // Path prefix, for ScopeSamePrefix
+	hosts    []string // Extra hosts, exact or "*.domain"
+	include  []urlPattern
+	exclude  []urlPattern
+}
+
+// urlPattern is a compiled include or exclude pattern
+type urlPattern struct {
+	re      *regexp.Regexp
+	fullURL bool // Matched against the full URL instead of the path and query
+}
+
+// newCrawlScope compiles the scope options of a job
+func newCrawlScope(opts ScrapeOptions, start *url.URL) (*crawlScope, error) {
+	scope := &crawlScope{
+		mode:     opts.Scope,
+		startKey: canonicalURL(start.String()),
+		host:     strings.ToLower(start.Hostname()),
+		hosts:    opts.AllowedHosts,
+	}
+
+	switch scope.mode {
+	case "", ScopeSameHost:
+		scope.mode = ScopeSameHost
+	case ScopeSameDomain:
+		// Hosts without a public suffix, like IPs and localhost, stay on their own
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(scope.host); err == nil {
+			scope.domain = domain
+		}
+	case ScopeSamePrefix:
+		scope.prefix = start.EscapedPath()
+		if !strings.HasSuffix(scope.prefix, "/") {
+			scope.prefix += "/"
+		}
+	default:
+		return nil, fmt.Errorf("invalid scope %q: use %q, %q or %q", opts.Scope, ScopeSameHost, ScopeSameDomain, ScopeSamePrefix)
+	}
+
+	var err error
+	if scope.include, err = compileURLPatterns(opts.Include); err != nil {
+		return nil, err
+	}
+	if scope.exclude, err = compileURLPatterns(opts.Exclude); err != nil {
+		return nil, err
+	}
+	return scope, nil
+}
+
+// compileURLPatterns compiles include/exclude patterns. Patterns starting
+// with "re:" are regular expressions matched anywhere in the full URL. Any
+// other pattern is a glob matched against the whole path and query, where
+// "*" matches any run of characters, "/" included.
+func compileURLPatterns(patterns []string) ([]urlPattern, error) {
+	compiled := make([]urlPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		expr, fullURL := strings.TrimPrefix(pattern, regexPatternPrefix), true
+		if expr == pattern {
+			expr, fullURL = "^"+strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")+"$", false
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, urlPattern{re: re, fullURL: fullURL})
+	}
+	return compiled, nil
+}
+
+// allows reports whether rawURL may be fetched: it must be in the scope's
+// hosts and path, match no exclude pattern, and match an include pattern if
+// there are any. The start URL is exempt from include patterns. A nil scope
+// allows everything.
+func (s *crawlScope) allows(rawURL string) bool {
+	if s == nil {
+		return true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if !s.allowsHost(u.Hostname()) {
+		return false
+	}
+	if s.mode == ScopeSamePrefix && strings.EqualFold(u.Hostname(), s.host) {
+		p := u.EscapedPath()
+		if p+"/" != s.prefix && !strings.HasPrefix(p, s.prefix) {
+			return false
+		}
+	}
+
+	if matchesAnyPattern(u, s.exclude) {
+		return false
+	}
+	if len(s.include) > 0 && !matchesAnyPattern(u, s.include) {
+		return canonicalURL(u.String()) == s.startKey
+	}
+	return true
+}
+
+// allowsHost reports whether host is covered by the scope mode or the extra hosts
+func (s *crawlScope) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == s.host || hostMatchesAny(host, s.hosts) {
+		return true
+	}
+	if s.mode == ScopeSameDomain && s.domain != "" {
+		domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+		return err == nil && domain == s.domain
+	}
+	return false
+}
+
+// matchesAnyPattern checks a URL against compiled include/exclude patterns.
+// Globs see the path and query, regular expressions the full URL.
+func matchesAnyPattern(u *url.URL, patterns []urlPattern) bool {
+	pathQuery := u.EscapedPath()
+	if u.RawQuery != "" {
+		pathQuery += "?" + u.RawQuery
+	}
+
+	for _, pattern := range patterns {
+		target := pathQuery
+		if pattern.fullURL {
+			target = u.String()
+		}
+		if pattern.re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectPolicy is the collector's redirect handler. It keeps redirects in
+// scope, stops after 10 like net/http does, and drops the Authorization
+// header when the host changes.
+func (s *crawlScope) redirectPolicy(req *http.Request, via []*http.Request) error {
+	if !s.allows(req.URL.String()) {
+		return fmt.Errorf("not following redirect to %s: %w", req.URL, errOutOfScope)
+	}
+	if len(via) >= 10 {
+		return http.ErrUseLastResponse
+	}
+	if last := via[len(via)-1]; req.URL.Host != last.URL.Host {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
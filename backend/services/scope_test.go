@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// mustScope builds the scope of a crawl starting at start
+func mustScope(t *testing.T, start string, opts ScrapeOptions) *crawlScope {
+	u, _ := url.Parse(start)
+	scope, err := newCrawlScope(opts, u)
+	if err != nil {
+		t.Fatalf("Failed to create scope: %v", err)
+	}
+	return scope
+}
+
+func TestCrawlScopeModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		opts  ScrapeOptions
+		url   string
+		want  bool
+	}{
+		{"same host", "https://shop.example.com/", ScrapeOptions{}, "http://shop.example.com/a", true},
+		{"other host", "https://shop.example.com/", ScrapeOptions{}, "https://www.example.com/a", false},
+		{"not http", "https://shop.example.com/", ScrapeOptions{}, "mailto:sales@example.com", false},
+		{"extra host", "https://shop.example.com/", ScrapeOptions{AllowedHosts: []string{"www.example.com"}}, "https://www.example.com/a", true},
+		{"extra wildcard host", "https://shop.example.com/", ScrapeOptions{AllowedHosts: []string{"*.cdn.net"}}, "https://img.cdn.net/a", true},
+		{"same domain", "https://shop.example.com/", ScrapeOptions{Scope: ScopeSameDomain}, "https://www.example.com/a", true},
+		{"same domain apex", "https://shop.example.com/", ScrapeOptions{Scope: ScopeSameDomain}, "https://example.com/a", true},
+		{"other domain", "https://shop.example.com/", ScrapeOptions{Scope: ScopeSameDomain}, "https://example.org/a", false},
+		{"public suffix", "https://shop.example.co.uk/", ScrapeOptions{Scope: ScopeSameDomain}, "https://other.co.uk/a", false},
+		{"same prefix", "https://example.com/products", ScrapeOptions{Scope: ScopeSamePrefix}, "https://example.com/products/stove", true},
+		{"prefix itself", "https://example.com/products/", ScrapeOptions{Scope: ScopeSamePrefix}, "https://example.com/products", true},
+		{"outside prefix", "https://example.com/products", ScrapeOptions{Scope: ScopeSamePrefix}, "https://example.com/products-old/stove", false},
+		{"prefix on other host", "https://example.com/products", ScrapeOptions{Scope: ScopeSamePrefix}, "https://www.example.com/products/stove", false},
+	}
+
+	for _, tt := range tests {
+		if got := mustScope(t, tt.start, tt.opts).allows(tt.url); got != tt.want {
+			t.Errorf("%s: allows(%s) = %v, want %v", tt.name, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestCrawlScopePatterns(t *testing.T) {
+	scope := mustScope(t, "https://example.com/", ScrapeOptions{
+		Include: []string{"/products/*", `re:/deals/\d+$`},
+		Exclude: []string{"*sort=*", "/products/*/reviews"},
+	})
+
+	tests := map[string]bool{
+		"https://example.com/":                       true, // The start URL is exempt from include patterns
+		"https://example.com/products/stove":         true,
+		"https://example.com/products/stove?sort=up": false,
+		"https://example.com/products/stove/reviews": false,
+		"https://example.com/deals/42":               true,
+		"https://example.com/deals/today":            false,
+		"https://example.com/about":                  false,
+	}
+	for u, want := range tests {
+		if got := scope.allows(u); got != want {
+			t.Errorf("allows(%s) = %v, want %v", u, got, want)
+		}
+	}
+
+	var nilScope *crawlScope
+	if !nilScope.allows("https://anywhere.com/") {
+		t.Error("Expected a nil scope to allow everything")
+	}
+}
+
+func TestInvalidCrawlScope(t *testing.T) {
+	start, _ := url.Parse("https://example.com/")
+	for _, opts := range []ScrapeOptions{
+		{Scope: "everything"},
+		{Include: []string{"re:("}},
+		{Exclude: []string{"re:[a-"}},
+	} {
+		if _, err := newCrawlScope(opts, start); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
+
+func TestScopedCrawl(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.RequestURI()]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+			return
+		case "/products/moved":
+			http.Redirect(w, r, "/about", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Page %s</h1>
+			<nav><a href="/products/a">A</a><a href="/about">About</a></nav>
+			<div class="products"><a href="/products/b?sort=price">B by price</a><a href="/products/moved">Moved</a></div>
+			<a href="https://elsewhere.example/">Elsewhere</a>
+		</body></html>`, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{
+		URL:             ts.URL + "/",
+		IgnoreRobotsTxt: true,
+		Include:         []string{"/products/*"},
+		Exclude:         []string{"*sort=*"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	waitForJob(t, jobID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]int{"/": 1, "/products/a": 1, "/products/moved": 1}
+	if len(hits) != len(want) {
+		t.Errorf("Expected only %v to be fetched, got %v", want, hits)
+	}
+	for path, n := range want {
+		if hits[path] != n {
+			t.Errorf("Expected %s to be fetched %d time(s), got %d", path, n, hits[path])
+		}
+	}
+
+	if _, err := StartScraping(ScrapeOptions{URL: ts.URL + "/", Include: []string{"re:("}}); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+}
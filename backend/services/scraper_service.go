@@ -2,10 +2,11 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,131 +17,555 @@ import (
 	"github.com/gocolly/colly/v2/extensions"
 )
 
-var (
-	scraping       bool
-	scrapingMutex  sync.Mutex
-	scrapeComplete chan bool = make(chan bool, 1)
-)
+// DefaultUserAgent identifies the crawler to sites and is matched against robots.txt groups
+const DefaultUserAgent = "ScrapeNServe/1.0"
 
 // ScraperConfig represents configuration options for the scraper
 type ScraperConfig struct {
-	MaxDepth          int
-	Parallelism       int
-	RequestDelay      time.Duration
-	RequestTimeout    time.Duration
-	FollowRedirects   bool
-	AllowedDomains    []string
-	DisallowedDomains []string
+	MaxDepth           int
+	Parallelism        int
+	RequestDelay       time.Duration
+	RequestTimeout     time.Duration
+	FollowRedirects    bool
+	AllowedDomains     []string
+	DisallowedDomains  []string
+	UserAgent          string        // Sent with every request; random browser agents are used when empty
+	RespectRobotsTxt   bool          // Skip URLs disallowed by robots.txt for UserAgent
+	BackoffInitial     time.Duration // Extra per-host delay after the first 429/503 response
+	BackoffMax         time.Duration // Cap on the exponential per-host delay
+	MaxAttempts        int           // Fetches per page before it is recorded as failed
+	RetryBackoff       time.Duration // Wait before the first retry; doubles for every later one
+	RetryStatusCodes   []int         // HTTP statuses that are retried
+	RetryNetworkErrors bool          // Retry timeouts, refused connections and truncated responses
+	Proxies            []string      // http, https or socks5 proxy URLs; requests go direct when empty
+	ProxySelection     string        // ProxyRoundRobin or ProxySticky
+	ProxyMaxFailures   int           // Consecutive failures before a proxy is evicted
+	ProxyCooldown      time.Duration // How long an evicted proxy is left out
 }
 
 // DefaultScraperConfig returns the default scraper configuration
 func DefaultScraperConfig() ScraperConfig {
 	return ScraperConfig{
-		MaxDepth:        2,       // Reduce default depth to avoid scraping too many pages
-		Parallelism:     4,       // Reduce parallelism to avoid overloading sites
-		RequestDelay:    500 * time.Millisecond,
-		RequestTimeout:  10 * time.Second,
-		FollowRedirects: true,
-	}
-}
-
-// StartScraping initiates the web scraping process
-func StartScraping(targetURL string, maxDepth int) (bool, error) {
-	// Use mutex to prevent multiple scraping processes
-	scrapingMutex.Lock()
-	if scraping {
-		scrapingMutex.Unlock()
-		return false, fmt.Errorf("scraping is already in progress")
-	}
-	scraping = true
-	scrapingMutex.Unlock()
-	
-	// Ensure we mark scraping as complete when we're done
-	defer func() {
-		scrapingMutex.Lock()
-		scraping = false
-		scrapingMutex.Unlock()
-	}()
+		MaxDepth:           2, // Reduce default depth to avoid scraping too many pages
+		Parallelism:        4, // Reduce parallelism to avoid overloading sites
+		RequestDelay:       500 * time.Millisecond,
+		RequestTimeout:     10 * time.Second,
+		FollowRedirects:    true,
+		UserAgent:          DefaultUserAgent,
+		RespectRobotsTxt:   true,
+		BackoffInitial:     2 * time.Second,
+		BackoffMax:         2 * time.Minute,
+		MaxAttempts:        3,
+		RetryBackoff:       time.Second,
+		RetryStatusCodes:   DefaultRetryStatusCodes,
+		RetryNetworkErrors: true,
+		ProxySelection:     ProxyRoundRobin,
+		ProxyMaxFailures:   3,
+		ProxyCooldown:      5 * time.Minute,
+	}
+}
 
-	// Parse the target URL to get the domain
-	parsedURL, err := url.Parse(targetURL)
+// StartScraping submits a new scraping job and returns its ID.
+// The crawl runs in the background; several jobs may run at once up to the
+// configured global cap.
+func StartScraping(opts ScrapeOptions) (string, error) {
+	// Validate the target URL before queuing the job
+	parsedURL, err := url.Parse(opts.URL)
 	if err != nil {
-		return false, fmt.Errorf("invalid URL: %w", err)
+		return "", fmt.Errorf("invalid URL: %w", err)
 	}
-	
-	domain := parsedURL.Hostname()
+	if parsedURL.Hostname() == "" {
+		return "", fmt.Errorf("invalid URL: missing host")
+	}
+	if _, err := parseProxyURLs(opts.Proxies); err != nil {
+		return "", err
+	}
+	if err := validateProxySelection(opts.ProxySelection); err != nil {
+		return "", err
+	}
+	// Check the login and auth with the stored credential filled in
+	session, err := withCredential(opts)
+	if err != nil {
+		return "", err
+	}
+	if err := validateSessionOptions(session); err != nil {
+		return "", err
+	}
+	if _, err := newCrawlScope(opts, parsedURL); err != nil {
+		return "", err
+	}
+	if err := validateBudget(opts); err != nil {
+		return "", err
+	}
+
+	job := jobManager.Submit(opts, runScrapeJob)
+	log.Printf("Queued scraping job %s for %s", job.ID(), opts.URL)
+
+	return job.ID(), nil
+}
+
+// runScrapeJob crawls the job's target URL and blocks until the crawl is done
+func runScrapeJob(job *Job) error {
+	parsedURL, err := url.Parse(job.Options.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	// Decrypt the stored credential for this run only. Its secrets and the
+	// inline ones are redacted from logs until the run is over.
+	session, err := withCredential(job.Options)
+	if err != nil {
+		return err
+	}
+	defer registerSessionSecrets(session)()
+
 	config := DefaultScraperConfig()
-	
+
 	// Override max depth if provided
-	if maxDepth > 0 {
-		config.MaxDepth = maxDepth
+	if job.Options.MaxDepth > 0 {
+		config.MaxDepth = job.Options.MaxDepth
 	}
-	
-	// Set allowed domains to just the target domain to avoid crawling beyond it
-	config.AllowedDomains = []string{domain}
-	
+
+	// Hosts, paths and patterns the crawl may fetch; colly's own domain
+	// check cannot express registered domains or wildcards
+	scope, err := newCrawlScope(job.Options, parsedURL)
+	if err != nil {
+		return err
+	}
+
+	// Sites we own can opt out of robots.txt checks
+	if job.Options.IgnoreRobotsTxt {
+		config.RespectRobotsTxt = false
+	}
+
+	// Route through the job's proxies, or the default ones
+	config.Proxies, config.ProxySelection = defaultProxyConfig()
+	if len(job.Options.Proxies) > 0 {
+		config.Proxies = job.Options.Proxies
+		config.ProxySelection = job.Options.ProxySelection
+	}
+	pool, err := newProxyPool(config, proxyHealth)
+	if err != nil {
+		return err
+	}
+
+	// robots.txt, sitemaps and the login step go through the proxies as well,
+	// so the server's own address is never exposed to the target
+	client := &http.Client{Timeout: config.RequestTimeout}
+	if pool != nil {
+		client.Transport = &http.Transport{Proxy: pool.proxyFunc}
+	}
+
 	// Initialize the collector with the domain
 	c := initializeCollector(config)
-	
+	c.SetRedirectHandler(scope.redirectPolicy)
+	if pool != nil {
+		c.SetProxyFunc(pool.proxyFunc)
+	}
+
+	// One cookie jar carries the job's cookies and login session across the crawl
+	jar, err := newSessionJar(session, parsedURL)
+	if err != nil {
+		return err
+	}
+	c.SetCookieJar(jar)
+
+	if session.Login != nil {
+		loginClient := &http.Client{Jar: jar, Timeout: config.RequestTimeout, Transport: client.Transport}
+		if err := submitLogin(loginClient, session, config.UserAgent); err != nil {
+			return err
+		}
+		log.Printf("[job %s] Logged in at %s", job.ID(), session.Login.URL)
+	}
+
+	// Slows down hosts that answer with 429 or 503, or ask for a Crawl-delay,
+	// on top of the fixed limits
+	throttle := newHostThrottle(config.BackoffInitial, config.BackoffMax)
+
 	// Context for scraping session
+	startTime := time.Now()
 	ctx := &scrapingContext{
 		processedItems: 0,
 		visitedURLs:    make(map[string]bool),
 		productURLs:    make(map[string]bool),
 		seenImages:     make(map[string]bool),
 		mu:             &sync.Mutex{},
-		startTime:      time.Now(),
+		startTime:      startTime,
+		job:            job,
+		upsert:         job.Options.Upsert,
+		frontier:       newCrawlFrontier(job.ID()),
+		scope:          scope,
+		budget: newCrawlBudget(job.Options, startTime, func(reason string) {
+			log.Printf("[job %s] Budget %s reached, stopping", job.ID(), reason)
+			job.update(func(r *models.ScrapeJob) { r.StopReason = reason })
+		}),
 	}
+	defer ctx.budget.release()
 
-	// Set up callbacks for different types of pages
-	setupProductPageCallbacks(c, ctx)
+	// Closed once the job is cancelled or out of budget, so retry backoffs
+	// and throttled hosts are not waited out
+	stopped := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		defer close(stopped)
+		select {
+		case <-job.Done():
+		case <-ctx.budget.done():
+		case <-finished:
+		}
+	}()
+
+	// Follow links, and let the highest priority matching extractor handle each page
 	setupListingPageCallbacks(c, ctx)
-	setupGenericPageCallbacks(c, ctx)
-	
+	setupExtractionCallbacks(c, ctx)
+
 	// Handle errors
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Error scraping %s: %v", r.Request.URL, err)
+		if errors.Is(err, errOutOfScope) {
+			log.Printf("[job %s] Skipping %s: %v", job.ID(), r.Request.URL, err)
+			ctx.frontier.mark(r.Request, models.FrontierSkipped)
+			return
+		}
+
+		// colly reports 304 as an error; the page is unchanged since the last crawl
+		if r.StatusCode == http.StatusNotModified {
+			log.Printf("[job %s] Not modified: %s", job.ID(), r.Request.URL)
+			throttle.succeeded(r.Request.URL.Host)
+			pool.succeeded(r.Request)
+			ctx.replayUnchangedPage(c, r)
+			ctx.frontier.mark(r.Request, models.FrontierDone)
+			return
+		}
+
+		log.Printf("[job %s] Error scraping %s: %v", job.ID(), r.Request.URL, err)
+		ctx.recordError(err)
+
+		if proxy := pool.failed(r.Request, r.StatusCode, err); proxy != nil {
+			log.Printf("[job %s] Evicting proxy %s for %v", job.ID(), redactProxyURL(proxy), config.ProxyCooldown)
+		}
+
+		// Slow the host down before any retry goes out
+		if isThrottlingStatus(r.StatusCode) {
+			var retryAfter string
+			if r.Headers != nil {
+				retryAfter = r.Headers.Get("Retry-After")
+			}
+			pause := throttle.throttled(r.Request.URL.Host, retryAfter)
+			log.Printf("[job %s] %s is throttling (%d), pausing it for %v", job.ID(), r.Request.URL.Host, r.StatusCode, pause)
+			ctx.recordThrottle(pause)
+		}
+
+		attempt := requestAttempt(r.Request)
+		halted := job.Cancelled() || ctx.budget.exhausted()
+		if !halted && shouldRetry(config, attempt, r.StatusCode, err) {
+			ctx.recordRetry()
+			if retryErr := retryRequest(config, r.Request, stopped); retryErr == nil {
+				log.Printf("[job %s] Retried %s (attempt %d of %d)", job.ID(), r.Request.URL, attempt+1, config.MaxAttempts)
+				return
+			}
+			// The job may have been stopped during the backoff
+			halted = job.Cancelled() || ctx.budget.exhausted()
+		}
+		// Leave the page pending so resuming a stopped job fetches it
+		if halted {
+			return
+		}
+
+		ctx.frontier.fail(r.Request, attempt, r.StatusCode, err)
+		ctx.recordFailedURL()
 	})
-	
+
+	// Count every page that was fetched
+	c.OnResponse(func(r *colly.Response) {
+		ctx.recordPage(len(r.Body))
+		ctx.budget.addBytes(len(r.Body))
+		throttle.succeeded(r.Request.URL.Host)
+		pool.succeeded(r.Request)
+	})
+
+	// Validators are shared by every job crawling a page, so jobs with a
+	// session neither use nor store them; a 304 could otherwise stand in
+	// for content the job was never sent
+	revalidate := !session.hasSession()
+
+	// A page is finished once every extraction callback has run on it
+	c.OnScraped(func(r *colly.Response) {
+		if revalidate {
+			savePageValidator(r)
+		}
+		ctx.frontier.mark(r.Request, models.FrontierDone)
+	})
+
 	// Before making a request
 	c.OnRequest(func(r *colly.Request) {
-		log.Printf("Visiting %s", r.URL.String())
-		ctx.mu.Lock()
-		ctx.visitedURLs[r.URL.String()] = true
-		ctx.mu.Unlock()
+		// Links are checked when found; this catches sitemap and resumed URLs
+		if !scope.allows(r.URL.String()) {
+			r.Abort()
+			return
+		}
+
+		// Persist the request first so a cancelled job can still be resumed
+		ctx.frontier.add(r)
+
+		// Once the job is cancelled or out of budget, drop queued requests and
+		// let in-flight ones drain
+		if job.Cancelled() || ctx.budget.exhausted() {
+			r.Abort()
+			return
+		}
+
+		if config.RespectRobotsTxt && !robots.allowed(client, r.URL, config.UserAgent) {
+			log.Printf("[job %s] Skipping %s: disallowed by robots.txt", job.ID(), r.URL.String())
+			ctx.recordRobotsSkip()
+			ctx.frontier.mark(r, models.FrontierSkipped)
+			r.Abort()
+			return
+		}
+
+		// Every host the crawl reaches gets its own Crawl-delay; robots.txt is
+		// cached, so this costs a lookup per request
+		if config.RespectRobotsTxt {
+			throttle.setFloor(r.URL.Host, robots.crawlDelay(client, r.URL, config.UserAgent))
+		}
+
+		// Hold the request while its host is backing off
+		if throttle.wait(r.URL.Host, stopped) > 0 && (job.Cancelled() || ctx.budget.exhausted()) {
+			r.Abort()
+			return
+		}
+
+		// Retries were paid for by the first attempt
+		if requestAttempt(r) == 1 && !ctx.budget.takePage() {
+			r.Abort()
+			return
+		}
+
+		applySession(session, r.URL, *r.Headers)
+
+		// Let unchanged pages answer 304 instead of sending them in full
+		if revalidate && !job.Options.FullRecrawl {
+			setConditionalHeaders(r)
+		}
+
+		// Pick the proxy last, so one evicted while waiting is not used
+		pool.assign(r)
+
+		log.Printf("[job %s] Visiting %s", job.ID(), r.URL.String())
+		ctx.markNew(ctx.visitedURLs, r.URL.String())
 	})
 
-	// Start scraping
-	if err := c.Visit(targetURL); err != nil {
-		return false, fmt.Errorf("failed to start scraping: %w", err)
+	if job.Resumed {
+		// Pick up the persisted frontier instead of starting over
+		if err := resumeFromFrontier(c, ctx); err != nil {
+			return fmt.Errorf("failed to resume scraping: %w", err)
+		}
+	} else {
+		// Start scraping
+		if err := c.Visit(job.Options.URL); err != nil {
+			return fmt.Errorf("failed to start scraping: %w", err)
+		}
+
+		// Seed pages that link-following alone would not reach
+		if job.Options.UseSitemaps {
+			seedFromSitemaps(c, job, parsedURL, config, scope, client)
+		}
 	}
 
 	// Wait for all requests to complete
 	c.Wait()
 
 	elapsed := time.Since(ctx.startTime)
-	log.Printf("Scraping complete. Processed %d items in %v.", ctx.processedItems, elapsed)
-	
-	// Non-blocking send to channel
-	select {
-	case scrapeComplete <- true:
-		// Successfully sent to channel
-	default:
-		// Channel is full or unavailable, just log it
-		log.Printf("Could not send to scrapeComplete channel")
+	if job.Cancelled() {
+		log.Printf("[job %s] Scraping cancelled. Processed %d items in %v.", job.ID(), ctx.processedItems, elapsed)
+		return nil
 	}
-	
-	return true, nil
+	if reason := ctx.budget.stopReason(); reason != "" {
+		// Keep the unfetched URLs, so resuming continues with a fresh budget
+		log.Printf("[job %s] Scraping stopped by %s. Processed %d items in %v.", job.ID(), reason, ctx.processedItems, elapsed)
+		return nil
+	}
+	log.Printf("[job %s] Scraping complete. Processed %d items in %v.", job.ID(), ctx.processedItems, elapsed)
+
+	// A finished crawl has nothing left to resume, unless pages failed
+	ctx.frontier.clear()
+
+	return nil
+}
+
+// resumeFromFrontier marks every URL the job already queued as visited, for
+// plain and product links alike, and requeues the ones that were never fetched
+func resumeFromFrontier(c *colly.Collector, ctx *scrapingContext) error {
+	pending, seen, err := ctx.frontier.load()
+	if err != nil {
+		return err
+	}
+
+	ctx.mu.Lock()
+	for _, key := range seen {
+		ctx.visitedURLs[key] = true
+		ctx.productURLs[key] = true
+	}
+	ctx.mu.Unlock()
+
+	requeued := 0
+	for _, row := range pending {
+		req, err := frontierRequest(c, row)
+		if err != nil {
+			log.Printf("[job %s] Dropping unreadable frontier URL %s: %v", ctx.job.ID(), row.FetchURL, err)
+			continue
+		}
+		if err := req.Do(); err == nil {
+			requeued++
+		}
+	}
+
+	log.Printf("[job %s] Resumed with %d of %d pending URLs", ctx.job.ID(), requeued, len(pending))
+	return nil
+}
+
+// seedFromSitemaps queues the pages listed in the site's sitemaps, skipping
+// those not modified since the cutoff. Sitemaps are fetched with client.
+func seedFromSitemaps(c *colly.Collector, job *Job, startURL *url.URL, config ScraperConfig, scope *crawlScope, client *http.Client) {
+	since := lastSuccessfulCrawl(job.Options.URL)
+	if job.Options.SitemapSince != nil {
+		since = *job.Options.SitemapSince
+	}
+
+	fetcher := &sitemapFetcher{
+		client:    client,
+		userAgent: config.UserAgent,
+		since:     since,
+		limit:     maxSitemapURLs,
+	}
+
+	seeded := 0
+	for _, pageURL := range fetcher.collect(discoverSitemaps(client, startURL)) {
+		if !scope.allows(pageURL) {
+			continue
+		}
+		// Visit rejects already queued URLs
+		if err := c.Visit(pageURL); err == nil {
+			seeded++
+		}
+	}
+
+	log.Printf("[job %s] Seeded %d URLs from sitemaps", job.ID(), seeded)
+	job.update(func(r *models.ScrapeJob) { r.SitemapURLs = seeded })
 }
 
 // scrapingContext stores the context for a scraping session
 type scrapingContext struct {
 	processedItems int
-	visitedURLs    map[string]bool
-	productURLs    map[string]bool
+	visitedURLs    map[string]bool // Keyed by canonical URL
+	productURLs    map[string]bool // Keyed by canonical URL
 	seenImages     map[string]bool
 	mu             *sync.Mutex
 	startTime      time.Time
+	job            *Job
+	upsert         bool           // Update changed items instead of only inserting new ones
+	frontier       *crawlFrontier // Persists queued and finished URLs; nil outside of jobs
+	scope          *crawlScope    // URLs the crawl may fetch; nil allows all
+	budget         *crawlBudget   // Limits of this run; nil for none
+}
+
+// markNew records the canonical form of rawURL in seen and reports whether
+// it was new, so variants of a URL are only crawled once
+func (ctx *scrapingContext) markNew(seen map[string]bool, rawURL string) bool {
+	key := canonicalURL(rawURL)
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// recordPage counts a fetched page and its size against the job
+func (ctx *scrapingContext) recordPage(size int) {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) {
+			r.PagesVisited++
+			r.BytesFetched += int64(size)
+		})
+	}
+}
+
+// recordNotModified counts a page answered 304, the bytes that saved and
+// the stored items it still holds
+func (ctx *scrapingContext) recordNotModified(bytesSaved int64, itemsUnchanged int) {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) {
+			r.NotModified++
+			r.BytesSaved += bytesSaved
+			r.ItemsUnchanged += itemsUnchanged
+		})
+	}
+}
+
+// recordError counts a failed request against the job
+func (ctx *scrapingContext) recordError(err error) {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) {
+			r.ErrorCount++
+			r.LastError = err.Error()
+		})
+	}
+}
+
+// recordThrottle counts a 429/503 response and the pause it caused
+func (ctx *scrapingContext) recordThrottle(pause time.Duration) {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) {
+			r.ThrottledResponses++
+			if seconds := pause.Seconds(); seconds > r.MaxBackoffSeconds {
+				r.MaxBackoffSeconds = seconds
+			}
+		})
+	}
+}
+
+// recordRetry counts a failed fetch that is tried again
+func (ctx *scrapingContext) recordRetry() {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) { r.Retries++ })
+	}
+}
+
+// recordFailedURL counts a page that still failed after its last attempt
+func (ctx *scrapingContext) recordFailedURL() {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) { r.FailedURLs++ })
+	}
+}
+
+// recordRobotsSkip counts a URL skipped because robots.txt disallows it
+func (ctx *scrapingContext) recordRobotsSkip() {
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) { r.RobotsSkipped++ })
+	}
+}
+
+// recordItemSaved counts a stored item by what happened to it. Callers must hold ctx.mu.
+func (ctx *scrapingContext) recordItemSaved(outcome saveOutcome) {
+	if outcome != itemUnchanged {
+		ctx.processedItems++
+	}
+	if ctx.job != nil {
+		ctx.job.update(func(r *models.ScrapeJob) {
+			switch outcome {
+			case itemCreated:
+				r.ItemsSaved++
+			case itemUpdated:
+				r.ItemsUpdated++
+			default:
+				r.ItemsUnchanged++
+			}
+		})
+	}
 }
 
 // initializeCollector creates and configures a new collector
@@ -169,81 +594,82 @@ func initializeCollector(config ScraperConfig) *colly.Collector {
 	// Set timeout
 	c.SetRequestTimeout(config.RequestTimeout)
 	
+	// Identify ourselves consistently so robots.txt rules match what we send
+	if config.UserAgent != "" {
+		c.UserAgent = config.UserAgent
+	} else {
+		extensions.RandomUserAgent(c)
+	}
+
 	// Add extensions
-	extensions.RandomUserAgent(c)
 	extensions.Referer(c)
 	
 	return c
 }
 
-// setupProductPageCallbacks sets up callbacks for product pages
-func setupProductPageCallbacks(c *colly.Collector, ctx *scrapingContext) {
-	// This selector should be adjusted based on the target site's structure
-	c.OnHTML("div.product, div.product-detail, div.item, article, .product", func(e *colly.HTMLElement) {
-		extractProductData(e, ctx)
-	})
-	
-	// Extract data from main content areas
-	c.OnHTML("main, #content, #main-content, .content", func(e *colly.HTMLElement) {
-		extractGenericContentData(e, ctx)
-	})
-}
-
 // setupListingPageCallbacks sets up callbacks for listing/category pages
 func setupListingPageCallbacks(c *colly.Collector, ctx *scrapingContext) {
 	// Handle pagination links
 	c.OnHTML("a.page, a.pagination__item, .pagination a, nav a", func(e *colly.HTMLElement) {
-		pageURL := e.Request.AbsoluteURL(e.Attr("href"))
-		if pageURL != "" && !ctx.visitedURLs[pageURL] {
-			e.Request.Visit(pageURL)
-		}
+		ctx.follow(c, e, ctx.visitedURLs)
 	})
 	
 	// Handle product links in listing pages
 	c.OnHTML("a.product-link, a.product-item, .product-grid a, .products a, article a", func(e *colly.HTMLElement) {
-		productURL := e.Request.AbsoluteURL(e.Attr("href"))
-		if productURL != "" {
-			ctx.mu.Lock()
-			if !ctx.productURLs[productURL] {
-				ctx.productURLs[productURL] = true
-				ctx.mu.Unlock()
-				e.Request.Visit(productURL)
-			} else {
-				ctx.mu.Unlock()
-			}
-		}
+		ctx.follow(c, e, ctx.productURLs)
 	})
 	
-	// Handle generic internal links
+	// Handle all other links
 	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
-		href := e.Attr("href")
-		// Only follow internal links
-		if isInternalLink(href, e.Request.URL.String()) {
-			linkURL := e.Request.AbsoluteURL(href)
-			ctx.mu.Lock()
-			if !ctx.visitedURLs[linkURL] {
-				ctx.visitedURLs[linkURL] = true
-				ctx.mu.Unlock()
-				e.Request.Visit(linkURL)
-			} else {
-				ctx.mu.Unlock()
-			}
-		}
+		ctx.follow(c, e, ctx.visitedURLs)
 	})
 }
 
-// setupGenericPageCallbacks sets up callbacks for generic pages
-func setupGenericPageCallbacks(c *colly.Collector, ctx *scrapingContext) {
-	// Try to detect product information on any page
-	c.OnHTML("body", func(e *colly.HTMLElement) {
-		// For product pages
-		if hasProductIndicators(e) {
-			extractProductData(e, ctx)
-			return
+// follow queues the link in e if the crawl's scope allows it and it is not
+// in seen yet
+func (ctx *scrapingContext) follow(c *colly.Collector, e *colly.HTMLElement, seen map[string]bool) {
+	link := e.Request.AbsoluteURL(e.Attr("href"))
+	if link == "" {
+		return
+	}
+	// Kept with the page's validators, to be followed again if it comes back unchanged
+	addPageValue(e.Request, pageLinksCtx, link)
+	if !ctx.scope.allows(link) || !ctx.markNew(seen, link) {
+		return
+	}
+	followLink(c, e.Request, link)
+}
+
+// followLink queues a link found on page one level deeper. Unlike
+// Request.Visit, which shares the page's context with every link on it, the
+// request gets a context of its own, so per-request state like the retry
+// attempt, frontier key and proxy is not mixed up between siblings.
+func followLink(c *colly.Collector, page *colly.Request, link string) error {
+	// Keep the Referer extension working, which reads the page from the context
+	req, err := crawlRequest(c, link, page.Depth+1, map[string]interface{}{"_referer": page.URL.String()})
+	if err != nil {
+		return err
+	}
+	return req.Do()
+}
+
+// setupExtractionCallbacks hands every page to the extractors and stores the
+// items of the first one that finds any
+func setupExtractionCallbacks(c *colly.Collector, ctx *scrapingContext) {
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		extractor, items := extractors.extract(e)
+		for _, item := range items {
+			// Skip if essential info is missing
+			if item.Title == "" || item.URL == "" {
+				continue
+			}
+			canonicalizeItemURL(e, &item)
+			if saveScrapedItem(ctx, item, extractor.Name()) {
+				addPageValue(e.Request, pageItemsCtx, item.URL)
+			} else {
+				e.Request.Ctx.Put(pageIncompleteCtx, true)
+			}
 		}
-		
-		// For general content
-		extractGenericContentData(e, ctx)
 	})
 }
 
@@ -257,40 +683,9 @@ func hasProductIndicators(e *colly.HTMLElement) bool {
 	return hasPriceElement && (hasAddToCartButton || hasProductTitle)
 }
 
-// isInternalLink checks if a URL is an internal link on the same domain
-func isInternalLink(href string, currentURL string) bool {
-	// Skip empty links, javascript links, and anchor links
-	if href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#") {
-		return false
-	}
-	
-	// Always follow relative links
-	if strings.HasPrefix(href, "/") {
-		return true
-	}
-	
-	// Check if the link is to the same domain
-	parsedHref, err := url.Parse(href)
-	if err != nil {
-		return false
-	}
-	
-	parsedCurrentURL, err := url.Parse(currentURL)
-	if err != nil {
-		return false
-	}
-	
-	// If the hostname is empty, it's a relative URL (already handled above)
-	if parsedHref.Hostname() == "" {
-		return true
-	}
-	
-	// Check if the hostnames match
-	return parsedHref.Hostname() == parsedCurrentURL.Hostname()
-}
 
 // extractGenericContentData extracts content from generic pages
-func extractGenericContentData(e *colly.HTMLElement, ctx *scrapingContext) {
+func extractGenericContentData(e *colly.HTMLElement) *models.ScrapedItem {
 	// Get the title from various common selectors
 	title := getFirstNonEmpty(e,
 		"h1",
@@ -350,7 +745,7 @@ func extractGenericContentData(e *colly.HTMLElement, ctx *scrapingContext) {
 	imageURL = e.Request.AbsoluteURL(imageURL)
 	
 	// Gather metadata using schema.org or Open Graph tags
-	metadata := map[string]string{
+	metadata := map[string]interface{}{
 		"contentType": "article",
 		"domain": e.Request.URL.Hostname(),
 		"path": e.Request.URL.Path,
@@ -415,13 +810,6 @@ func extractGenericContentData(e *colly.HTMLElement, ctx *scrapingContext) {
 		metadata["publishDate"] = publishDate
 	}
 	
-	metadataJSON, _ := json.Marshal(metadata)
-	
-	// Skip if essential info is missing
-	if title == "" || url == "" {
-		return
-	}
-	
 	// Create a new ScrapedItem
 	item := models.ScrapedItem{
 		Title:       title,
@@ -430,28 +818,22 @@ func extractGenericContentData(e *colly.HTMLElement, ctx *scrapingContext) {
 		ImageURL:    imageURL,
 		Price:       0.0, // Most articles don't have prices
 		ScrapedAt:   time.Now(),
-		Metadata:    string(metadataJSON),
 	}
 	
-	// Save to database only if it's a new URL
-	ctx.mu.Lock()
-	defer ctx.mu.Unlock()
+	// Structured data is more reliable than the selectors above
+	applyStructuredData(e, &item, metadata)
 	
-	result := db.DB.Where(models.ScrapedItem{URL: url}).FirstOrCreate(&item)
-	if result.Error != nil {
-		log.Printf("Error saving article %s: %v", url, result.Error)
-		return
-	}
+	metadataJSON, _ := json.Marshal(metadata)
+	item.Metadata = string(metadataJSON)
 	
-	if result.RowsAffected > 0 {
-		// It's a new item
-		ctx.processedItems++
-		log.Printf("Saved new article: %s", title)
-	}
+	return &item
 }
 
+// priceSelectors locate the displayed price on pages without structured data
+var priceSelectors = []string{".price", ".product-price", "span.amount", ".current-price"}
+
 // extractProductData extracts product data from an HTML element
-func extractProductData(e *colly.HTMLElement, ctx *scrapingContext) {
+func extractProductData(e *colly.HTMLElement) *models.ScrapedItem {
 	// Try multiple selectors for each field to handle different site structures
 	title := getFirstNonEmpty(e,
 		"h1.product-title",
@@ -489,40 +871,19 @@ func extractProductData(e *colly.HTMLElement, ctx *scrapingContext) {
 	imageURL = e.Request.AbsoluteURL(imageURL)
 	
 	// Try to extract price with different selectors
-	priceStr := getFirstNonEmpty(e,
-		".price",
-		".product-price",
-		"span.amount",
-		".current-price",
-	)
-	
-	// Clean up price string and convert to float
-	price := 0.0
-	if priceStr != "" {
-		// Remove currency symbols and formatting
-		priceStr = strings.ReplaceAll(priceStr, "$", "")
-		priceStr = strings.ReplaceAll(priceStr, "£", "")
-		priceStr = strings.ReplaceAll(priceStr, "€", "")
-		priceStr = strings.ReplaceAll(priceStr, ",", "")
-		priceStr = strings.TrimSpace(priceStr)
-		
-		if p, err := strconv.ParseFloat(priceStr, 64); err == nil {
-			price = p
-		}
-	}
+	priceStr := getFirstNonEmpty(e, priceSelectors...)
 	
-	// Skip if we couldn't extract essential information
-	if title == "" || url == "" {
-		return
-	}
+	// Resolve separators and currency using the page language
+	price := parsePrice(priceStr, pageLanguage(e))
 	
 	// Create metadata with all available product information
-	metadata := map[string]string{
+	metadata := map[string]interface{}{
 		"category": getFirstNonEmpty(e, ".breadcrumbs", ".category", ".product-category"),
 		"vendor": getFirstNonEmpty(e, ".vendor", ".brand", ".manufacturer"),
 		"sku": getFirstNonEmpty(e, ".sku", ".product-sku", "span.sku"),
 		"availability": getFirstNonEmpty(e, ".stock", ".availability", ".inventory"),
 	}
+	price.metadata(metadata)
 	
 	// Add any additional structured data if available
 	e.ForEach("meta[property^='og:']", func(_ int, elem *colly.HTMLElement) {
@@ -535,34 +896,67 @@ func extractProductData(e *colly.HTMLElement, ctx *scrapingContext) {
 		}
 	})
 	
-	metadataJSON, _ := json.Marshal(metadata)
-	
 	// Create a new ScrapedItem
 	item := models.ScrapedItem{
 		Title:       title,
 		Description: description,
 		URL:         url,
 		ImageURL:    imageURL,
-		Price:       price,
+		Price:       price.Amount,
+		Currency:    price.Currency,
+		PriceText:   price.Raw,
 		ScrapedAt:   time.Now(),
-		Metadata:    string(metadataJSON),
 	}
 	
-	// Save to database only if it's a new URL
+	// Structured data is more reliable than the selectors above
+	applyStructuredData(e, &item, metadata)
+	
+	metadataJSON, _ := json.Marshal(metadata)
+	item.Metadata = string(metadataJSON)
+	
+	return &item
+}
+
+// saveScrapedItem stores the item, updating an existing row in upsert mode,
+// and records the price seen on this crawl. kind names the extractor in logs.
+// Returns false if the item was refused by the budget or could not be stored.
+func saveScrapedItem(ctx *scrapingContext, item models.ScrapedItem, kind string) bool {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
-	
-	result := db.DB.Where(models.ScrapedItem{URL: url}).FirstOrCreate(&item)
-	if result.Error != nil {
-		log.Printf("Error saving item %s: %v", url, result.Error)
-		return
+
+	var jobID string
+	if ctx.job != nil {
+		jobID = ctx.job.ID()
 	}
-	
-	if result.RowsAffected > 0 {
-		// It's a new item
-		ctx.processedItems++
-		log.Printf("Saved new item: %s", title)
+
+	if !ctx.budget.canSaveItem() {
+		return false
+	}
+
+	outcome, err := storeScrapedItem(&item, ctx.upsert, jobID)
+	if err != nil {
+		log.Printf("Error saving %s %s: %v", kind, item.URL, err)
+		return false
+	}
+
+	if outcome != itemUnchanged {
+		ctx.budget.addItem()
 	}
+	ctx.recordItemSaved(outcome)
+	switch outcome {
+	case itemCreated:
+		log.Printf("Saved new %s: %s", kind, item.Title)
+	case itemUpdated:
+		log.Printf("Updated changed %s: %s", kind, item.Title)
+	}
+
+	recordPriceObservation(item.ID, item, jobID)
+	return true
+}
+
+// pageLanguage returns the page's declared language, e.g. "de-DE", or ""
+func pageLanguage(e *colly.HTMLElement) string {
+	return strings.TrimSpace(e.DOM.Closest("html").AttrOr("lang", ""))
 }
 
 // getFirstNonEmpty tries multiple selectors and returns the first non-empty result
@@ -585,19 +979,14 @@ func getFirstNonEmptyAttr(e *colly.HTMLElement, attr string, selectors ...string
 	return ""
 }
 
-// IsScrapingInProgress checks if scraping is currently active
+// IsScrapingInProgress checks if any scraping job is currently running
 func IsScrapingInProgress() bool {
-	scrapingMutex.Lock()
-	result := scraping
-	scrapingMutex.Unlock()
-	return result
+	return jobManager.RunningCount() > 0
 }
 
-// ResetScrapingState resets the scraping state (mainly for testing)
+// ResetScrapingState forgets finished jobs (mainly for testing)
 func ResetScrapingState() {
-	scrapingMutex.Lock()
-	scraping = false
-	scrapingMutex.Unlock()
+	jobManager.Reset()
 }
 
 // GetScrapedItems retrieves items from the database with simple limit
@@ -3,8 +3,6 @@ package services
 import (
 	"net/http"
 	"net/http/httptest"
-	"net/url"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -235,94 +233,3 @@ func TestExtractProductData(t *testing.T) {
 		t.Errorf("Expected processedItems to be 1, got %d", ctx.processedItems)
 	}
 }
-
-func TestExtractWikipediaData(t *testing.T) {
-	// We'll create a simpler test without database interaction
-
-	// Create a test Wikipedia-style HTML page
-	html := `
-		<html>
-			<head>
-				<title>Test Wikipedia Article - Wikipedia</title>
-			</head>
-			<body>
-				<h1 id="firstHeading">Test Wikipedia Article</h1>
-				<div id="mw-content-text">
-					<p>This is the first paragraph of the Wikipedia article about a test subject.</p>
-					<p>This is the second paragraph with more details.</p>
-					<div class="infobox">
-						<img src="/wiki/images/test.jpg" alt="Test image" />
-						<table>
-							<tr>
-								<th>Born</th>
-								<td>January 1, 2000</td>
-							</tr>
-							<tr>
-								<th>Occupation</th>
-								<td>Test Subject</td>
-							</tr>
-						</table>
-					</div>
-					<div class="toc">
-						<ul>
-							<li>Section 1</li>
-							<li>Section 2</li>
-							<li>Section 3</li>
-						</ul>
-					</div>
-				</div>
-				<div id="mw-normal-catlinks">
-					<ul>
-						<li>Category 1</li>
-						<li>Category 2</li>
-					</ul>
-				</div>
-				<div id="footer-info-lastmod">
-					This page was last edited on 1 January 2023
-				</div>
-			</body>
-		</html>
-	`
-
-	// Create a test server
-	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
-	}))
-	defer ts.Close()
-
-	// Mock the URL to look like Wikipedia
-	mockURL, _ := url.Parse(ts.URL)
-	mockURL.Host = "en.wikipedia.org"
-
-	// Create a collector and make a request
-	c := colly.NewCollector()
-	var foundTitle, foundDescription string
-
-	// Add a request callback to override the URL in the response to make it look like Wikipedia
-	c.OnRequest(func(r *colly.Request) {
-		r.URL = mockURL
-	})
-
-	c.OnHTML("body", func(e *colly.HTMLElement) {
-		// The URL field of the Request field of the HTMLElement would normally be the original URL,
-		// but we've overridden it to be our mock URL
-		if strings.Contains(e.Request.URL.Host, "wikipedia.org") {
-			// For testing, we'll just extract the title and description
-			foundTitle = e.ChildText("h1#firstHeading")
-			foundDescription = e.ChildText("#mw-content-text p:first-of-type")
-		}
-	})
-
-	c.Visit(ts.URL)
-
-	// Check the extracted data
-	if foundTitle != "Test Wikipedia Article" {
-		t.Errorf("Expected title to be 'Test Wikipedia Article', got '%s'", foundTitle)
-	}
-
-	expectedDesc := "This is the first paragraph of the Wikipedia article about a test subject."
-	if foundDescription != expectedDesc {
-		t.Errorf("Expected description to be '%s', got '%s'", expectedDesc, foundDescription)
-	}
-}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/arkouda/scrape-n-serve/utils"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Authentication types for AuthOptions
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// maxLoginPageSize caps how much of a login page is read
+const maxLoginPageSize = 2 << 20
+
+// ErrLoginFailed is returned when a job's login step does not succeed
+var ErrLoginFailed = errors.New("login failed")
+
+// CookieOption is a cookie sent with a job's requests from the start
+type CookieOption struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain,omitempty"` // Defaults to the start URL's host
+	Path   string `json:"path,omitempty"`
+}
+
+// AuthOptions adds HTTP authentication to every request of a job
+type AuthOptions struct {
+	Type     string `json:"type"` // AuthBasic or AuthBearer
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// LoginOptions describes a login form submitted before the crawl starts.
+// Hidden fields of the form, like CSRF tokens, are sent along with Fields.
+type LoginOptions struct {
+	URL           string            `json:"url"`                      // Page with the login form
+	Fields        map[string]string `json:"fields"`                   // Filled in over the form's own values
+	FormSelector  string            `json:"form_selector,omitempty"`  // Defaults to the first form with a password field
+	SuccessText   string            `json:"success_text,omitempty"`   // Must appear on the page reached after logging in
+	SuccessCookie string            `json:"success_cookie,omitempty"` // Must be set once logged in
+}
+
+// validateSessionOptions checks the cookies, authentication and login of a job
+func validateSessionOptions(opts ScrapeOptions) error {
+	for _, cookie := range opts.Cookies {
+		if cookie.Name == "" {
+			return errors.New("invalid cookie: missing name")
+		}
+	}
+	// Cookies must be accepted for their domain; credentials are checked once used
+	if start, err := url.Parse(opts.URL); err == nil && start.Host != "" {
+		if _, err := newSessionJar(opts, start); err != nil {
+			return err
+		}
+	}
+
+	if auth := opts.Auth; auth != nil {
+		switch auth.Type {
+		case AuthBasic:
+			if auth.Username == "" {
+				return errors.New("invalid auth: basic auth needs a username")
+			}
+		case AuthBearer:
+			if auth.Token == "" {
+				return errors.New("invalid auth: bearer auth needs a token")
+			}
+		default:
+			return fmt.Errorf("invalid auth type %q: use %q or %q", auth.Type, AuthBasic, AuthBearer)
+		}
+	}
+
+	if login := opts.Login; login != nil {
+		u, err := url.Parse(login.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid login URL %q", login.URL)
+		}
+		if len(login.Fields) == 0 {
+			return errors.New("invalid login: no form fields")
+		}
+	}
+	return nil
+}
+
+// isSensitiveHeader reports whether a header likely carries a secret, like
+// Authorization or X-Api-Key
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, hint := range []string{"auth", "token", "key", "secret", "password", "cookie", "session"} {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// registerSessionSecrets redacts a job's passwords, tokens, login fields,
+// cookie values, sensitive headers and proxy passwords from log output until
+// the returned func is called
+func registerSessionSecrets(opts ScrapeOptions) (unregister func()) {
+	var values []string
+	for _, raw := range opts.Proxies {
+		values = append(values, proxyPassword(raw))
+	}
+	if auth := opts.Auth; auth != nil {
+		values = append(values, auth.Password, auth.Token)
+		if auth.Type == AuthBasic {
+			req := http.Request{Header: http.Header{}}
+			req.SetBasicAuth(auth.Username, auth.Password)
+			values = append(values, strings.TrimPrefix(req.Header.Get("Authorization"), "Basic "))
+		}
+	}
+	if opts.Login != nil {
+		for _, value := range opts.Login.Fields {
+			values = append(values, value)
+		}
+	}
+	for _, cookie := range opts.Cookies {
+		values = append(values, cookie.Value)
+	}
+	for name, value := range opts.Headers {
+		if isSensitiveHeader(name) {
+			values = append(values, value)
+		}
+	}
+
+	unregisters := make([]func(), len(values))
+	for i, value := range values {
+		unregisters[i] = utils.RegisterSecret(value)
+	}
+	return func() {
+		for _, unregister := range unregisters {
+			unregister()
+		}
+	}
+}
+
+// redacted returns a copy of opts with inline secrets replaced by
+// utils.Redacted and proxy credentials as in redactProxyURL, fit to be
+// stored with the job record
+func (opts ScrapeOptions) redacted() ScrapeOptions {
+	if opts.Auth != nil {
+		auth := *opts.Auth
+		if auth.Password != "" {
+			auth.Password = utils.Redacted
+		}
+		if auth.Token != "" {
+			auth.Token = utils.Redacted
+		}
+		opts.Auth = &auth
+	}
+	if opts.Login != nil {
+		login := *opts.Login
+		login.Fields = make(map[string]string, len(opts.Login.Fields))
+		for name := range opts.Login.Fields {
+			login.Fields[name] = utils.Redacted
+		}
+		opts.Login = &login
+	}
+	if len(opts.Cookies) > 0 {
+		cookies := make([]CookieOption, len(opts.Cookies))
+		for i, cookie := range opts.Cookies {
+			cookie.Value = utils.Redacted
+			cookies[i] = cookie
+		}
+		opts.Cookies = cookies
+	}
+	if len(opts.Headers) > 0 {
+		headers := make(map[string]string, len(opts.Headers))
+		for name, value := range opts.Headers {
+			if isSensitiveHeader(name) {
+				value = utils.Redacted
+			}
+			headers[name] = value
+		}
+		opts.Headers = headers
+	}
+	if len(opts.Proxies) > 0 {
+		proxies := make([]string, len(opts.Proxies))
+		for i, raw := range opts.Proxies {
+			proxies[i] = utils.Redacted
+			if u, err := url.Parse(strings.TrimSpace(raw)); err == nil {
+				proxies[i] = redactProxyURL(u)
+			}
+		}
+		opts.Proxies = proxies
+	}
+	return opts
+}
+
+// hasRedactedSecrets reports whether options read back from a job record
+// lost inline secrets to redaction, proxy credentials included
+func (opts ScrapeOptions) hasRedactedSecrets() bool {
+	for _, proxy := range opts.Proxies {
+		if hasRedactedProxy(proxy) {
+			return true
+		}
+	}
+	data, err := json.Marshal(opts)
+	return err != nil || strings.Contains(string(data), utils.Redacted)
+}
+
+// hasSession reports whether a job sends its own headers, cookies,
+// authentication or login, so the pages it gets may differ from what an
+// anonymous crawl of the same URLs sees
+func (opts ScrapeOptions) hasSession() bool {
+	return len(opts.Headers) > 0 || len(opts.Cookies) > 0 || opts.Auth != nil || opts.Login != nil
+}
+
+// newSessionJar creates the cookie jar shared by a job's login step and
+// crawl, holding the job's cookies. Cookies without a domain are set for the
+// start URL's host, the others for their own domain and its subdomains. A
+// cookie the jar refuses, e.g. for a public suffix like "co.uk", is an error.
+func newSessionJar(opts ScrapeOptions, startURL *url.URL) (http.CookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range opts.Cookies {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+
+		target := url.URL{Scheme: startURL.Scheme, Host: startURL.Host, Path: path}
+		if domain := strings.TrimPrefix(c.Domain, "."); domain != "" {
+			if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+				return nil, fmt.Errorf("invalid cookie %q: %q is a public suffix", c.Name, c.Domain)
+			}
+			target.Host = domain
+		}
+		jar.SetCookies(&target, []*http.Cookie{{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: path}})
+		if !hasCookie(jar, &target, c.Name) {
+			return nil, fmt.Errorf("invalid cookie %q: cannot be set for domain %q", c.Name, c.Domain)
+		}
+	}
+	return jar, nil
+}
+
+// sessionHost reports whether target is on the host of the job's start URL
+// or login page. Other hosts the crawl scope lets in never get the job's
+// headers or credentials.
+func sessionHost(opts ScrapeOptions, target *url.URL) bool {
+	hosts := []string{opts.URL}
+	if opts.Login != nil {
+		hosts = append(hosts, opts.Login.URL)
+	}
+	for _, raw := range hosts {
+		if u, err := url.Parse(raw); err == nil && u.Host != "" && strings.EqualFold(u.Host, target.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySession sets a job's static headers and authentication on a request
+// to target, if it goes to the start or login host
+func applySession(opts ScrapeOptions, target *url.URL, header http.Header) {
+	if !sessionHost(opts, target) {
+		return
+	}
+
+	for name, value := range opts.Headers {
+		header.Set(name, value)
+	}
+
+	if auth := opts.Auth; auth != nil {
+		switch auth.Type {
+		case AuthBasic:
+			req := http.Request{Header: header}
+			req.SetBasicAuth(auth.Username, auth.Password)
+		case AuthBearer:
+			header.Set("Authorization", "Bearer "+auth.Token)
+		}
+	}
+}
+
+// submitLogin fills in the job's login form and submits it with client,
+// whose cookie jar then carries the session into the crawl
+func submitLogin(client *http.Client, opts ScrapeOptions, userAgent string) error {
+	login := opts.Login
+
+	page, err := sessionRequest(client, opts, userAgent, http.MethodGet, login.URL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+
+	method, action, values, err := loginForm(page, login)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+
+	var body io.Reader
+	if method == http.MethodGet {
+		action.RawQuery = values.Encode()
+	} else {
+		body = strings.NewReader(values.Encode())
+	}
+	result, err := sessionRequest(client, opts, userAgent, method, action.String(), body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLoginFailed, err)
+	}
+
+	if login.SuccessText != "" && !strings.Contains(result.body, login.SuccessText) {
+		return fmt.Errorf("%w: %q not found on %s", ErrLoginFailed, login.SuccessText, result.url)
+	}
+	if login.SuccessCookie != "" && !hasCookie(client.Jar, result.url, login.SuccessCookie) {
+		return fmt.Errorf("%w: cookie %q was not set", ErrLoginFailed, login.SuccessCookie)
+	}
+	return nil
+}
+
+// loginPage is a page fetched during the login step
+type loginPage struct {
+	url  *url.URL // After redirects
+	body string
+}
+
+// sessionRequest sends a login step request with the job's headers and
+// authentication. Error statuses are returned as errors.
+func sessionRequest(client *http.Client, opts ScrapeOptions, userAgent, method, target string, body io.Reader) (*loginPage, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	applySession(opts, req.URL, req.Header)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s %s returned %d", method, resp.Request.URL, resp.StatusCode)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxLoginPageSize))
+	if err != nil {
+		return nil, err
+	}
+	return &loginPage{url: resp.Request.URL, body: string(content)}, nil
+}
+
+// loginForm finds the login form on page and returns how to submit it: its
+// method, its action and its fields with the configured ones filled in.
+// Without a form the fields are posted back to the page.
+func loginForm(page *loginPage, login *LoginOptions) (string, *url.URL, url.Values, error) {
+	values := url.Values{}
+	pageURL := *page.url
+	method, action := http.MethodPost, &pageURL
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page.body))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var form *goquery.Selection
+	if login.FormSelector != "" {
+		form = doc.Find(login.FormSelector).First()
+		if form.Length() == 0 {
+			return "", nil, nil, fmt.Errorf("no form matches %q on %s", login.FormSelector, page.url)
+		}
+	} else {
+		form = doc.Find("form").FilterFunction(func(_ int, s *goquery.Selection) bool {
+			return s.Find(`input[type="password"]`).Length() > 0
+		}).First()
+	}
+
+	if form.Length() > 0 {
+		// Login forms without a method are posted, keeping passwords out of URLs
+		if m := strings.ToUpper(strings.TrimSpace(form.AttrOr("method", ""))); m == http.MethodGet {
+			method = m
+		}
+		if href := strings.TrimSpace(form.AttrOr("action", "")); href != "" {
+			if resolved, err := page.url.Parse(href); err == nil {
+				action = resolved
+			}
+		}
+
+		form.Find("input[name], textarea[name], select[name]").Each(func(_ int, field *goquery.Selection) {
+			name := field.AttrOr("name", "")
+			switch strings.ToLower(field.AttrOr("type", "")) {
+			case "submit", "button", "image", "reset", "file":
+				return
+			case "checkbox", "radio":
+				if _, checked := field.Attr("checked"); !checked {
+					return
+				}
+			}
+
+			value := field.AttrOr("value", "")
+			switch goquery.NodeName(field) {
+			case "textarea":
+				value = field.Text()
+			case "select":
+				value = field.Find("option[selected]").First().AttrOr("value", "")
+			}
+			values.Set(name, value)
+		})
+	}
+
+	for name, value := range login.Fields {
+		values.Set(name, value)
+	}
+	return method, action, values, nil
+}
+
+// hasCookie reports whether the jar holds a cookie named name for u
+func hasCookie(jar http.CookieJar, u *url.URL, name string) bool {
+	if jar == nil {
+		return false
+	}
+	for _, cookie := range jar.Cookies(u) {
+		if cookie.Name == name {
+			return true
+		}
+	}
+	return false
+}
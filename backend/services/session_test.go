@@ -0,0 +1,307 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestLoginForm(t *testing.T) {
+	pageURL, _ := url.Parse("https://shop.example.com/account/login")
+	page := &loginPage{url: pageURL, body: `<html><body>
+		<form action="/search" method="get"><input name="q"></form>
+		<form action="session" method="post">
+			<input type="hidden" name="csrf" value="t0k3n">
+			<input type="text" name="user" value="">
+			<input type="password" name="pass">
+			<input type="checkbox" name="remember" value="1" checked>
+			<input type="checkbox" name="newsletter" value="1">
+			<input type="submit" name="go" value="Log in">
+		</form></body></html>`}
+
+	method, action, values, err := loginForm(page, &LoginOptions{Fields: map[string]string{"user": "ann", "pass": "secret"}})
+	if err != nil {
+		t.Fatalf("Failed to read login form: %v", err)
+	}
+	if method != http.MethodPost || action.String() != "https://shop.example.com/account/session" {
+		t.Errorf("Expected POST to the form action, got %s %s", method, action)
+	}
+	want := url.Values{"csrf": {"t0k3n"}, "user": {"ann"}, "pass": {"secret"}, "remember": {"1"}}
+	if values.Encode() != want.Encode() {
+		t.Errorf("Expected form values %s, got %s", want.Encode(), values.Encode())
+	}
+
+	// Without a form the fields go back to the page itself
+	page.body = "<html><body>Sign in with the API</body></html>"
+	method, action, values, _ = loginForm(page, &LoginOptions{Fields: map[string]string{"user": "ann"}})
+	if method != http.MethodPost || action.String() != pageURL.String() || values.Get("user") != "ann" {
+		t.Errorf("Expected the fields posted to the login page, got %s %s %v", method, action, values)
+	}
+
+	if _, _, _, err := loginForm(page, &LoginOptions{FormSelector: "#login", Fields: map[string]string{"user": "ann"}}); err == nil {
+		t.Error("Expected an error when the form selector matches nothing")
+	}
+}
+
+func TestApplySession(t *testing.T) {
+	start, _ := url.Parse("https://shop.example/catalog")
+	header := http.Header{"User-Agent": {DefaultUserAgent}}
+	applySession(ScrapeOptions{
+		URL:     "https://shop.example/",
+		Headers: map[string]string{"User-Agent": "PartnerBot", "X-Partner": "acme"},
+		Auth:    &AuthOptions{Type: AuthBasic, Username: "ann", Password: "secret"},
+	}, start, header)
+
+	if header.Get("User-Agent") != "PartnerBot" || header.Get("X-Partner") != "acme" {
+		t.Errorf("Expected the static headers to be set, got %v", header)
+	}
+	req := http.Request{Header: header}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "ann" || pass != "secret" {
+		t.Errorf("Expected basic auth for ann, got %q %q", user, pass)
+	}
+
+	bearer := ScrapeOptions{
+		URL:   "https://shop.example/",
+		Auth:  &AuthOptions{Type: AuthBearer, Token: "abc"},
+		Login: &LoginOptions{URL: "https://accounts.example/login"},
+	}
+	login, _ := url.Parse("https://accounts.example/session")
+	header = http.Header{}
+	applySession(bearer, login, header)
+	if header.Get("Authorization") != "Bearer abc" {
+		t.Errorf("Expected a bearer token on the login host, got %q", header.Get("Authorization"))
+	}
+
+	// Other hosts the scope allows, like subdomains, get nothing
+	for _, raw := range []string{"https://cdn.shop.example/a", "https://partner.example/", "https://shop.example:8443/"} {
+		other, _ := url.Parse(raw)
+		header = http.Header{}
+		applySession(bearer, other, header)
+		if len(header) != 0 {
+			t.Errorf("Expected no session headers for %s, got %v", raw, header)
+		}
+	}
+}
+
+func TestValidateSessionOptions(t *testing.T) {
+	invalid := []ScrapeOptions{
+		{Cookies: []CookieOption{{Value: "x"}}},
+		{Auth: &AuthOptions{Type: "digest"}},
+		{Auth: &AuthOptions{Type: AuthBearer}},
+		{Login: &LoginOptions{URL: "/login", Fields: map[string]string{"user": "ann"}}},
+		{Login: &LoginOptions{URL: "https://example.com/login"}},
+		{URL: "https://shop.example.co.uk/", Cookies: []CookieOption{{Name: "region", Domain: "co.uk"}}},
+	}
+	for _, opts := range invalid {
+		if err := validateSessionOptions(opts); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+
+	valid := ScrapeOptions{
+		Cookies: []CookieOption{{Name: "region", Value: "eu"}},
+		Auth:    &AuthOptions{Type: AuthBasic, Username: "ann"},
+		Login:   &LoginOptions{URL: "https://example.com/login", Fields: map[string]string{"user": "ann"}},
+	}
+	if err := validateSessionOptions(valid); err != nil {
+		t.Errorf("Expected valid session options, got %v", err)
+	}
+}
+
+func TestNewSessionJar(t *testing.T) {
+	start, _ := url.Parse("https://shop.example.com/catalog")
+	jar, err := newSessionJar(ScrapeOptions{Cookies: []CookieOption{
+		{Name: "cart", Value: "1"},
+		{Name: "region", Value: "eu", Domain: "www.example.com"},
+		{Name: "consent", Value: "yes", Domain: ".example.com"},
+		{Name: "beta", Value: "on", Path: "/beta"},
+	}}, start)
+	if err != nil {
+		t.Fatalf("Failed to create jar: %v", err)
+	}
+
+	tests := map[string]string{
+		"https://shop.example.com/catalog": "cart=1; consent=yes",
+		"https://shop.example.com/beta/x":  "beta=on; cart=1; consent=yes",
+		"https://www.example.com/":         "consent=yes; region=eu",
+		"https://example.org/":             "",
+	}
+	for rawURL, want := range tests {
+		u, _ := url.Parse(rawURL)
+		var names []string
+		for _, cookie := range jar.Cookies(u) {
+			names = append(names, cookie.String())
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, "; "); got != want {
+			t.Errorf("Expected cookies %q for %s, got %q", want, rawURL, got)
+		}
+	}
+}
+
+// newLoginSite serves a catalog that needs a session from its login form,
+// a region cookie, a partner header and a bearer token
+func newLoginSite(hits map[string]int, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/login":
+			fmt.Fprint(w, `<html><body><form action="/session" method="post">
+				<input type="hidden" name="csrf" value="t0k3n">
+				<input name="user"><input type="password" name="pass">
+			</form></body></html>`)
+		case "/session":
+			if r.FormValue("csrf") != "t0k3n" || r.FormValue("user") != "ann" || r.FormValue("pass") != "secret" {
+				http.Redirect(w, r, "/login?error=1", http.StatusFound)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "ok", Path: "/"})
+			http.Redirect(w, r, "/account", http.StatusFound)
+		case "/account":
+			fmt.Fprint(w, "<html><body>Welcome back</body></html>")
+		default:
+			session, _ := r.Cookie("session")
+			region, _ := r.Cookie("region")
+			if session == nil || region == nil || region.Value != "eu" ||
+				r.Header.Get("X-Partner") != "acme" || r.Header.Get("Authorization") != "Bearer abc" {
+				http.Error(w, "log in first", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><body><h1>Catalog %s</h1><a href="/a">A</a></body></html>`, r.URL.Path)
+		}
+	}))
+}
+
+func TestAuthenticatedCrawl(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := newLoginSite(hits, &mu)
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{
+		URL:     ts.URL + "/",
+		Headers: map[string]string{"X-Partner": "acme"},
+		Cookies: []CookieOption{{Name: "region", Value: "eu"}},
+		Auth:    &AuthOptions{Type: AuthBearer, Token: "abc"},
+		Login: &LoginOptions{
+			URL:           ts.URL + "/login",
+			Fields:        map[string]string{"user": "ann", "pass": "secret"},
+			SuccessText:   "Welcome",
+			SuccessCookie: "session",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.State != models.JobStateSucceeded || job.FailedURLs != 0 {
+		t.Fatalf("Expected the crawl to succeed, got %s (%s) with %d failed", job.State, job.LastError, job.FailedURLs)
+	}
+	if job.PagesVisited != 2 {
+		t.Errorf("Expected both catalog pages to be fetched, got %d", job.PagesVisited)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/session"] != 1 || hits["/"] != 1 || hits["/a"] != 1 {
+		t.Errorf("Expected one login and one fetch per page, got %v", hits)
+	}
+}
+
+func TestSessionStaysOnStartHost(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		for _, name := range []string{"Authorization", "X-Partner"} {
+			if r.Header.Get(name) != "" {
+				leaked = append(leaked, r.URL.Path+" "+name)
+			}
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><h1>Partner</h1></body></html>`)
+	}))
+	defer other.Close()
+
+	start := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Partner") != "acme" || r.Header.Get("Authorization") != "Bearer abc" {
+			http.Error(w, "log in first", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Catalog</h1><a href="%s/offers">Offers</a></body></html>`, other.URL)
+	}))
+	defer start.Close()
+
+	// Both test servers listen on 127.0.0.1, so the other one is in scope
+	jobID, err := StartScraping(ScrapeOptions{
+		URL:             start.URL + "/",
+		IgnoreRobotsTxt: true,
+		Headers:         map[string]string{"X-Partner": "acme"},
+		Auth:            &AuthOptions{Type: AuthBearer, Token: "abc"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.PagesVisited != 2 || job.ErrorCount != 0 {
+		t.Errorf("Expected both pages to be fetched, got %d fetched and %d errors", job.PagesVisited, job.ErrorCount)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaked) != 0 {
+		t.Errorf("Expected no session headers on the other host, got %v", leaked)
+	}
+}
+
+func TestLoginFailureFailsJob(t *testing.T) {
+	setupItemStoreDB(t)
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := newLoginSite(hits, &mu)
+	defer ts.Close()
+
+	jobID, err := StartScraping(ScrapeOptions{
+		URL: ts.URL + "/",
+		Login: &LoginOptions{
+			URL:         ts.URL + "/login",
+			Fields:      map[string]string{"user": "ann", "pass": "wrong"},
+			SuccessText: "Welcome",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to start job: %v", err)
+	}
+	job := waitForJob(t, jobID)
+
+	if job.State != models.JobStateFailed || !strings.Contains(job.LastError, "login failed") {
+		t.Errorf("Expected the job to fail on login, got %s (%s)", job.State, job.LastError)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/"] != 0 {
+		t.Errorf("Expected no crawl after a failed login, got %d fetches of /", hits["/"])
+	}
+}
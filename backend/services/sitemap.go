@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSitemapURLs bounds how many page URLs a single job seeds from sitemaps
+	maxSitemapURLs = 10000
+	// maxSitemapDepth bounds how deeply sitemap indexes may nest
+	maxSitemapDepth = 5
+	// maxSitemapBytes bounds the size of a single (decompressed) sitemap file
+	maxSitemapBytes = 50 << 20
+)
+
+// sitemapDocument covers both <urlset> sitemaps and <sitemapindex> files
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntry is a single <url> or <sitemap> element
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapLastModLayouts are the W3C datetime variants allowed in <lastmod>
+var sitemapLastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// sitemapFetcher walks sitemaps and sitemap indexes collecting page URLs
+type sitemapFetcher struct {
+	client    *http.Client
+	userAgent string
+	since     time.Time // Entries last modified before this are skipped; zero keeps everything
+	limit     int
+}
+
+// discoverSitemaps returns the sitemaps advertised in robots.txt for the URL's
+// host, falling back to /sitemap.xml. robots.txt is fetched with client.
+func discoverSitemaps(client *http.Client, u *url.URL) []string {
+	if listed := robots.sitemaps(client, u); len(listed) > 0 {
+		return listed
+	}
+	return []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}
+}
+
+// collect returns the page URLs reachable from the given sitemaps
+func (f *sitemapFetcher) collect(sitemapURLs []string) []string {
+	var pages []string
+	seen := make(map[string]bool)
+
+	var walk func(sitemapURL string, depth int)
+	walk = func(sitemapURL string, depth int) {
+		if seen[sitemapURL] || depth > maxSitemapDepth || len(pages) >= f.limit {
+			return
+		}
+		seen[sitemapURL] = true
+
+		doc, err := f.fetch(sitemapURL)
+		if err != nil {
+			log.Printf("Could not read sitemap %s: %v", sitemapURL, err)
+			return
+		}
+
+		for _, child := range doc.Sitemaps {
+			if f.changed(child) {
+				walk(strings.TrimSpace(child.Loc), depth+1)
+			}
+		}
+
+		for _, entry := range doc.URLs {
+			if len(pages) >= f.limit {
+				return
+			}
+			if loc := strings.TrimSpace(entry.Loc); loc != "" && f.changed(entry) {
+				pages = append(pages, loc)
+			}
+		}
+	}
+
+	for _, sitemapURL := range sitemapURLs {
+		walk(sitemapURL, 0)
+	}
+	return pages
+}
+
+// changed reports whether an entry may have changed since the cutoff.
+// Entries without a usable lastmod are always kept.
+func (f *sitemapFetcher) changed(entry sitemapEntry) bool {
+	if f.since.IsZero() {
+		return true
+	}
+	lastMod, ok := parseSitemapLastMod(entry.LastMod)
+	return !ok || lastMod.After(f.since)
+}
+
+// fetch downloads and decodes a sitemap, transparently handling gzip
+func (f *sitemapFetcher) fetch(sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequest("GET", sitemapURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Sniff the gzip magic bytes rather than trusting the extension or headers
+	body := bufio.NewReader(resp.Body)
+	var reader io.Reader = body
+	if magic, err := body.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(reader, maxSitemapBytes)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// parseSitemapLastMod parses a <lastmod> value in any W3C datetime precision
+func parseSitemapLastMod(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range sitemapLastModLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSitemapFetcherCollect(t *testing.T) {
+	mux := http.NewServeMux()
+	var base string
+
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>` + base + `/products.xml.gz</loc><lastmod>2024-03-01</lastmod></sitemap>
+	<sitemap><loc>` + base + `/archive.xml</loc><lastmod>2020-01-01</lastmod></sitemap>
+</sitemapindex>`))
+	})
+
+	mux.HandleFunc("/products.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>` + base + `/p/new</loc><lastmod>2024-02-15T10:00:00+00:00</lastmod></url>
+	<url><loc>` + base + `/p/old</loc><lastmod>2023-06-01</lastmod></url>
+	<url><loc>` + base + `/p/undated</loc></url>
+</urlset>`))
+		gz.Close()
+		w.Write(buf.Bytes())
+	})
+
+	mux.HandleFunc("/archive.xml", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected unchanged child sitemap not to be fetched")
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	base = ts.URL
+
+	fetcher := &sitemapFetcher{
+		client: ts.Client(),
+		since:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		limit:  100,
+	}
+
+	pages := fetcher.collect([]string{ts.URL + "/sitemap.xml"})
+	sort.Strings(pages)
+
+	expected := []string{ts.URL + "/p/new", ts.URL + "/p/undated"}
+	if len(pages) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, pages)
+	}
+	for i := range expected {
+		if pages[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], pages[i])
+		}
+	}
+}
+
+func TestParseSitemapLastMod(t *testing.T) {
+	values := []string{
+		"2024-01-02",
+		"2024-01-02T03:04:05Z",
+		"2024-01-02T03:04:05.123+01:00",
+		"2024-01-02T03:04+01:00",
+	}
+	for _, value := range values {
+		if _, ok := parseSitemapLastMod(value); !ok {
+			t.Errorf("Expected %q to parse", value)
+		}
+	}
+
+	if _, ok := parseSitemapLastMod("yesterday"); ok {
+		t.Error("Expected invalid lastmod to be rejected")
+	}
+}
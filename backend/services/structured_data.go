@@ -0,0 +1,378 @@
+package services
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+// structuredDataKey caches parsed structured data on the colly request context
+const structuredDataKey = "structuredData"
+
+// structuredData holds the schema.org fields we map onto a ScrapedItem,
+// gathered from JSON-LD, microdata and RDFa
+type structuredData struct {
+	Type          string
+	Name          string
+	Description   string
+	Image         string
+	Price         string
+	PriceCurrency string
+	SKU           string
+	Brand         string
+	Availability  string
+	DatePublished string
+	Author        string
+	Breadcrumbs   []string
+
+	// Raw trees as found on the page, kept in the item's metadata
+	JSONLD    []interface{}
+	Microdata []interface{}
+	RDFa      []interface{}
+}
+
+// isProduct reports whether the page describes a schema.org Product
+func (sd *structuredData) isProduct() bool {
+	return sd != nil && sd.Type == "Product"
+}
+
+// pageStructuredData returns the structured data for the element's page,
+// parsing it once per request
+func pageStructuredData(e *colly.HTMLElement) *structuredData {
+	if cached, ok := e.Request.Ctx.GetAny(structuredDataKey).(*structuredData); ok {
+		return cached
+	}
+
+	// JSON-LD usually sits in <head>, outside the element a callback fired for
+	root := e.DOM.Closest("html")
+	if root.Length() == 0 {
+		root = e.DOM
+	}
+
+	sd := parseStructuredData(root)
+	e.Request.Ctx.Put(structuredDataKey, sd)
+	return sd
+}
+
+// applyStructuredData prefers the page's structured data over values found
+// with CSS selectors
+func applyStructuredData(e *colly.HTMLElement, item *models.ScrapedItem, metadata map[string]interface{}) {
+	sd := pageStructuredData(e)
+	if sd == nil {
+		return
+	}
+
+	sd.apply(item, metadata)
+	if item.ImageURL != "" {
+		item.ImageURL = e.Request.AbsoluteURL(item.ImageURL)
+	}
+}
+
+// parseStructuredData reads JSON-LD, microdata and RDFa under root and extracts
+// the main Product or Article. JSON-LD wins when several formats describe the
+// page. Returns nil if the page has no structured data at all.
+func parseStructuredData(root *goquery.Selection) *structuredData {
+	sd := &structuredData{
+		JSONLD:    parseJSONLD(root),
+		Microdata: parseMicrodata(root),
+		RDFa:      parseRDFa(root),
+	}
+	if len(sd.JSONLD) == 0 && len(sd.Microdata) == 0 && len(sd.RDFa) == 0 {
+		return nil
+	}
+
+	var nodes []map[string]interface{}
+	for _, tree := range [][]interface{}{sd.JSONLD, sd.Microdata, sd.RDFa} {
+		nodes = append(nodes, flattenSchemaNodes(tree)...)
+	}
+
+	for _, node := range nodes {
+		if hasSchemaType(node, "BreadcrumbList") && sd.Breadcrumbs == nil {
+			sd.Breadcrumbs = breadcrumbNames(node)
+		}
+	}
+
+	if product := findSchemaNode(nodes, "Product"); product != nil {
+		sd.Type = "Product"
+		sd.fillCommon(product)
+		sd.SKU = schemaText(product["sku"])
+		sd.Brand = schemaText(product["brand"])
+
+		if offer := firstSchemaObject(product["offers"]); offer != nil {
+			sd.Price = schemaText(offer["price"])
+			if sd.Price == "" {
+				// AggregateOffer
+				sd.Price = schemaText(offer["lowPrice"])
+			}
+			sd.PriceCurrency = schemaText(offer["priceCurrency"])
+			sd.Availability = schemaEnum(schemaText(offer["availability"]))
+		}
+	} else if article := findSchemaNode(nodes, "Article", "NewsArticle", "BlogPosting", "TechArticle", "ScholarlyArticle", "Report"); article != nil {
+		sd.Type = "Article"
+		sd.fillCommon(article)
+		if sd.Name == "" {
+			sd.Name = schemaText(article["headline"])
+		}
+	}
+
+	return sd
+}
+
+// parseJSONLD decodes every JSON-LD script under root, skipping invalid ones
+func parseJSONLD(root *goquery.Selection) []interface{} {
+	var docs []interface{}
+	root.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &doc); err != nil {
+			return
+		}
+		docs = append(docs, doc)
+	})
+	return docs
+}
+
+// fillCommon copies the fields shared by Products and Articles
+func (sd *structuredData) fillCommon(node map[string]interface{}) {
+	sd.Name = schemaText(node["name"])
+	sd.Description = schemaText(node["description"])
+	sd.Image = schemaURL(node["image"])
+	sd.DatePublished = schemaText(node["datePublished"])
+	sd.Author = schemaNames(node["author"])
+}
+
+// apply overwrites item fields and metadata with the structured values that are present
+func (sd *structuredData) apply(item *models.ScrapedItem, metadata map[string]interface{}) {
+	if sd == nil {
+		return
+	}
+
+	if sd.Name != "" {
+		item.Title = sd.Name
+	}
+	if sd.Description != "" {
+		item.Description = sd.Description
+	}
+	if sd.Image != "" {
+		item.ImageURL = sd.Image
+	}
+	if sd.Price != "" {
+		// schema.org prices use a decimal point regardless of the page language
+		if price := parsePrice(sd.Price, ""); price.Amount > 0 {
+			item.Price = price.Amount
+			item.PriceText = price.Raw
+			item.Currency = price.Currency
+		}
+	}
+	if sd.PriceCurrency != "" {
+		item.Currency = strings.ToUpper(sd.PriceCurrency)
+	}
+
+	optional := map[string]string{
+		"schemaType":   sd.Type,
+		"sku":          sd.SKU,
+		"brand":        sd.Brand,
+		"availability": sd.Availability,
+		"publishDate":  sd.DatePublished,
+		"author":       sd.Author,
+	}
+	for key, value := range optional {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	if len(sd.Breadcrumbs) > 0 {
+		metadata["category"] = strings.Join(sd.Breadcrumbs, " > ")
+	}
+
+	raw := map[string][]interface{}{
+		"jsonld":    sd.JSONLD,
+		"microdata": sd.Microdata,
+		"rdfa":      sd.RDFa,
+	}
+	for key, tree := range raw {
+		if len(tree) > 0 {
+			metadata[key] = tree
+		}
+	}
+}
+
+// flattenSchemaNodes lists every object in the tree, parents before the
+// objects nested in their properties, arrays and @graph containers
+func flattenSchemaNodes(doc interface{}) []map[string]interface{} {
+	switch v := doc.(type) {
+	case []interface{}:
+		var nodes []map[string]interface{}
+		for _, child := range v {
+			nodes = append(nodes, flattenSchemaNodes(child)...)
+		}
+		return nodes
+	case map[string]interface{}:
+		nodes := []map[string]interface{}{v}
+
+		// Visit properties in a stable order so the first match is deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			nodes = append(nodes, flattenSchemaNodes(v[key])...)
+		}
+		return nodes
+	}
+	return nil
+}
+
+// findSchemaNode returns the first node having any of the given types
+func findSchemaNode(nodes []map[string]interface{}, types ...string) map[string]interface{} {
+	for _, node := range nodes {
+		if hasSchemaType(node, types...) {
+			return node
+		}
+	}
+	return nil
+}
+
+// hasSchemaType checks a node's @type, which may be a string or a list
+func hasSchemaType(node map[string]interface{}, types ...string) bool {
+	var nodeTypes []string
+	switch t := node["@type"].(type) {
+	case string:
+		nodeTypes = []string{t}
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				nodeTypes = append(nodeTypes, s)
+			}
+		}
+	}
+
+	for _, nodeType := range nodeTypes {
+		nodeType = schemaEnum(nodeType)
+		for _, want := range types {
+			if nodeType == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaEnum strips a schema.org URL or prefix, e.g. "https://schema.org/InStock" -> "InStock"
+func schemaEnum(value string) string {
+	if i := strings.LastIndexAny(value, "/:"); i >= 0 {
+		return value[i+1:]
+	}
+	return value
+}
+
+// schemaText renders a scalar, or the name of an object, as a string
+func schemaText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		return schemaText(v["name"])
+	case []interface{}:
+		if len(v) > 0 {
+			return schemaText(v[0])
+		}
+	}
+	return ""
+}
+
+// schemaNames joins the names of one or more people or organizations
+func schemaNames(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return schemaText(value)
+	}
+
+	var names []string
+	for _, v := range list {
+		if name := schemaText(v); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// schemaURL extracts a URL from a string, an ImageObject or a list of either
+func schemaURL(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		if url := schemaURL(v["url"]); url != "" {
+			return url
+		}
+		return schemaURL(v["contentUrl"])
+	case []interface{}:
+		if len(v) > 0 {
+			return schemaURL(v[0])
+		}
+	}
+	return ""
+}
+
+// firstSchemaObject returns value if it is an object, or the first object in a list
+func firstSchemaObject(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		for _, child := range v {
+			if obj, ok := child.(map[string]interface{}); ok {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// breadcrumbNames returns the names in a BreadcrumbList ordered by position
+func breadcrumbNames(node map[string]interface{}) []string {
+	elements, _ := node["itemListElement"].([]interface{})
+
+	type crumb struct {
+		position float64
+		name     string
+	}
+	var crumbs []crumb
+	for i, element := range elements {
+		obj, ok := element.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name := schemaText(obj["name"])
+		if name == "" {
+			name = schemaText(obj["item"])
+		}
+		if name == "" {
+			continue
+		}
+
+		position, ok := obj["position"].(float64)
+		if !ok {
+			position = float64(i + 1)
+		}
+		crumbs = append(crumbs, crumb{position: position, name: name})
+	}
+
+	sort.SliceStable(crumbs, func(i, j int) bool { return crumbs[i].position < crumbs[j].position })
+
+	names := make([]string, len(crumbs))
+	for i, c := range crumbs {
+		names[i] = c.name
+	}
+	return names
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/arkouda/scrape-n-serve/models"
+)
+
+func TestParseJSONLDProductInGraph(t *testing.T) {
+	html := `
+		<html>
+			<head>
+				<script type="application/ld+json">
+				{
+					"@context": "https://schema.org",
+					"@graph": [
+						{"@type": "WebSite", "name": "Example Shop"},
+						{
+							"@type": "BreadcrumbList",
+							"itemListElement": [
+								{"@type": "ListItem", "position": 2, "name": "Shoes"},
+								{"@type": "ListItem", "position": 1, "name": "Home"}
+							]
+						},
+						{
+							"@type": ["Product"],
+							"name": "Trail Runner",
+							"description": "A lightweight shoe.",
+							"image": [{"@type": "ImageObject", "url": "/img/trail.jpg"}],
+							"sku": "TR-1",
+							"brand": {"@type": "Brand", "name": "Acme"},
+							"offers": {
+								"@type": "Offer",
+								"price": 89.5,
+								"priceCurrency": "USD",
+								"availability": "https://schema.org/InStock"
+							}
+						}
+					]
+				}
+				</script>
+				<script type="application/ld+json">not json</script>
+			</head>
+			<body><h1>Ignored Title</h1></body>
+		</html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sd := parseStructuredData(doc.Selection)
+
+	if !sd.isProduct() {
+		t.Fatalf("Expected a Product, got %+v", sd)
+	}
+
+	item := models.ScrapedItem{Title: "Ignored Title"}
+	metadata := map[string]interface{}{}
+	sd.apply(&item, metadata)
+
+	if item.Title != "Trail Runner" {
+		t.Errorf("Expected title 'Trail Runner', got '%s'", item.Title)
+	}
+	if item.Price != 89.5 {
+		t.Errorf("Expected price 89.5, got %v", item.Price)
+	}
+	if item.Currency != "USD" {
+		t.Errorf("Expected currency 'USD', got '%s'", item.Currency)
+	}
+	if item.ImageURL != "/img/trail.jpg" {
+		t.Errorf("Expected image '/img/trail.jpg', got '%s'", item.ImageURL)
+	}
+
+	expected := map[string]string{
+		"sku":          "TR-1",
+		"brand":        "Acme",
+		"availability": "InStock",
+		"category":     "Home > Shoes",
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("Expected metadata %s to be '%s', got '%v'", key, value, metadata[key])
+		}
+	}
+
+	if _, ok := metadata["jsonld"]; !ok {
+		t.Error("Expected raw JSON-LD to be kept in metadata")
+	}
+}
+
+func TestParseJSONLDArticle(t *testing.T) {
+	html := `
+		<html><head>
+			<script type="application/ld+json">
+			[{
+				"@type": "NewsArticle",
+				"headline": "Big News",
+				"datePublished": "2024-05-01T08:00:00Z",
+				"author": [{"@type": "Person", "name": "A. Writer"}, {"@type": "Person", "name": "B. Editor"}]
+			}]
+			</script>
+		</head><body></body></html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	sd := parseStructuredData(doc.Selection)
+
+	if sd == nil || sd.Type != "Article" {
+		t.Fatalf("Expected an Article, got %+v", sd)
+	}
+	if sd.Name != "Big News" {
+		t.Errorf("Expected headline to be used as name, got '%s'", sd.Name)
+	}
+	if sd.Author != "A. Writer, B. Editor" {
+		t.Errorf("Expected both authors, got '%s'", sd.Author)
+	}
+}
+
+func TestParseJSONLDMissing(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader("<html><body></body></html>"))
+	if sd := parseStructuredData(doc.Selection); sd != nil {
+		t.Errorf("Expected nil for a page without structured data, got %+v", sd)
+	}
+}
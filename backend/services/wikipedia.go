@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/arkouda/scrape-n-serve/models"
+	"github.com/gocolly/colly/v2"
+)
+
+// wikipediaDomains are the hosts handled by the Wikipedia extractor
+var wikipediaDomains = []string{"*.wikipedia.org"}
+
+// wikipediaLastEditedLayout is the date format of the English footer, e.g.
+// "This page was last edited on 1 January 2023, at 10:15 (UTC)."
+const wikipediaLastEditedLayout = "2 January 2006, at 15:04"
+
+// wikipediaArticle holds the fields pulled from a Wikipedia article page
+type wikipediaArticle struct {
+	Title        string
+	Lead         string            // First non-empty paragraph of the article body
+	Image        string            // Main image, usually the infobox image
+	Infobox      map[string]string // Infobox label -> value rows
+	Categories   []string
+	LastModified string // RFC 3339 when the footer date parses, otherwise the footer text
+}
+
+// isWikipediaURL reports whether the URL is served by a Wikipedia host
+func isWikipediaURL(u *url.URL) bool {
+	return hostMatchesAny(u.Hostname(), wikipediaDomains)
+}
+
+// parseWikipediaArticle extracts the article fields under root. Returns nil
+// for pages without a first heading, such as special and search pages.
+func parseWikipediaArticle(root *goquery.Selection) *wikipediaArticle {
+	title := wikipediaText(root.Find("h1#firstHeading").First())
+	if title == "" {
+		return nil
+	}
+
+	article := &wikipediaArticle{
+		Title:   title,
+		Infobox: make(map[string]string),
+	}
+
+	// Hatnotes, infoboxes and empty spacer paragraphs come before the lead
+	root.Find("#mw-content-text p").EachWithBreak(func(_ int, p *goquery.Selection) bool {
+		if p.HasClass("mw-empty-elt") || p.ParentsFiltered(".infobox, table, .hatnote").Length() > 0 {
+			return true
+		}
+		article.Lead = wikipediaText(p)
+		return article.Lead == ""
+	})
+
+	infobox := root.Find(".infobox").First()
+	infobox.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		label := wikipediaText(row.Find("th").First())
+		value := wikipediaText(row.Find("td").First())
+		if label != "" && value != "" {
+			article.Infobox[label] = value
+		}
+	})
+
+	article.Image = infobox.Find("img").First().AttrOr("src", "")
+	if article.Image == "" {
+		article.Image = root.Find("meta[property='og:image']").AttrOr("content", "")
+	}
+	if article.Image == "" {
+		article.Image = root.Find("#mw-content-text figure img, #mw-content-text .thumb img").First().AttrOr("src", "")
+	}
+
+	root.Find("#mw-normal-catlinks ul li").Each(func(_ int, li *goquery.Selection) {
+		if category := wikipediaText(li); category != "" {
+			article.Categories = append(article.Categories, category)
+		}
+	})
+
+	article.LastModified = parseWikipediaLastEdited(wikipediaText(root.Find("#footer-info-lastmod").First()))
+
+	return article
+}
+
+// parseWikipediaLastEdited converts the footer sentence to RFC 3339, keeping
+// the text as is for languages or layouts it does not recognize
+func parseWikipediaLastEdited(text string) string {
+	date := strings.TrimPrefix(text, "This page was last edited on ")
+	date = strings.TrimSuffix(strings.TrimSuffix(date, "."), " (UTC)")
+
+	if t, err := time.Parse(wikipediaLastEditedLayout, date); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	if t, err := time.Parse("2 January 2006", date); err == nil {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return text
+}
+
+// wikipediaText returns the element's text without reference markers and
+// edit links, with whitespace collapsed
+func wikipediaText(s *goquery.Selection) string {
+	if s.Length() == 0 {
+		return ""
+	}
+
+	clean := s.Clone()
+	clean.Find("sup.reference, .mw-editsection, style, .noprint").Remove()
+	return strings.Join(strings.Fields(clean.Text()), " ")
+}
+
+// extractWikipediaData builds an item for a Wikipedia article with its
+// infobox, categories and last-modified date in the metadata
+func extractWikipediaData(e *colly.HTMLElement) *models.ScrapedItem {
+	root := e.DOM.Closest("html")
+	if root.Length() == 0 {
+		root = e.DOM
+	}
+
+	article := parseWikipediaArticle(root)
+	if article == nil {
+		return nil
+	}
+
+	imageURL := article.Image
+	if imageURL != "" {
+		imageURL = e.Request.AbsoluteURL(imageURL)
+	}
+
+	metadata := map[string]interface{}{
+		"contentType": "article",
+		"extractor":   "wikipedia",
+		"domain":      e.Request.URL.Hostname(),
+		"path":        e.Request.URL.Path,
+	}
+	if len(article.Infobox) > 0 {
+		metadata["infobox"] = article.Infobox
+	}
+	if len(article.Categories) > 0 {
+		metadata["categories"] = article.Categories
+	}
+	if article.LastModified != "" {
+		metadata["lastModified"] = article.LastModified
+	}
+	if lang := pageLanguage(e); lang != "" {
+		metadata["language"] = lang
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+
+	item := models.ScrapedItem{
+		Title:       article.Title,
+		Description: article.Lead,
+		URL:         e.Request.URL.String(),
+		ImageURL:    imageURL,
+		ScrapedAt:   time.Now(),
+		Metadata:    string(metadataJSON),
+	}
+
+	return &item
+}
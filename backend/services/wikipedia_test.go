@@ -0,0 +1,106 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestExtractWikipediaData(t *testing.T) {
+	// Create a test Wikipedia-style HTML page
+	html := `
+		<html>
+			<head>
+				<title>Test Wikipedia Article - Wikipedia</title>
+			</head>
+			<body>
+				<h1 id="firstHeading">Test Wikipedia Article</h1>
+				<div id="mw-content-text">
+					<div class="hatnote">For other uses, see Test (disambiguation).</div>
+					<p class="mw-empty-elt"></p>
+					<table class="infobox">
+						<tr><td colspan="2"><img src="//upload.wikimedia.org/test.jpg" alt="Test image" /></td></tr>
+						<tr>
+							<th>Born</th>
+							<td>January 1, 2000<sup class="reference">[1]</sup></td>
+						</tr>
+						<tr>
+							<th>Occupation</th>
+							<td>Test
+								Subject</td>
+						</tr>
+					</table>
+					<p>This is the first paragraph of the Wikipedia article<sup class="reference">[2]</sup> about a test subject.</p>
+					<p>This is the second paragraph with more details.</p>
+				</div>
+				<div id="mw-normal-catlinks">
+					<a href="/wiki/Help:Category">Categories</a>:
+					<ul>
+						<li><a href="/wiki/Category:One">Category 1</a></li>
+						<li><a href="/wiki/Category:Two">Category 2</a></li>
+					</ul>
+				</div>
+				<ul>
+					<li id="footer-info-lastmod"> This page was last edited on 1 January 2023, at 10:15<span class="anonymous-show">&#160;(UTC)</span>.</li>
+				</ul>
+			</body>
+		</html>
+	`
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+	article := parseWikipediaArticle(doc.Selection)
+	if article == nil {
+		t.Fatal("Expected an article, got nil")
+	}
+
+	if article.Title != "Test Wikipedia Article" {
+		t.Errorf("Expected title to be 'Test Wikipedia Article', got '%s'", article.Title)
+	}
+
+	expectedLead := "This is the first paragraph of the Wikipedia article about a test subject."
+	if article.Lead != expectedLead {
+		t.Errorf("Expected lead to be '%s', got '%s'", expectedLead, article.Lead)
+	}
+
+	if article.Image != "//upload.wikimedia.org/test.jpg" {
+		t.Errorf("Expected the infobox image, got '%s'", article.Image)
+	}
+
+	if article.Infobox["Born"] != "January 1, 2000" || article.Infobox["Occupation"] != "Test Subject" {
+		t.Errorf("Unexpected infobox rows: %v", article.Infobox)
+	}
+
+	if strings.Join(article.Categories, "|") != "Category 1|Category 2" {
+		t.Errorf("Unexpected categories: %v", article.Categories)
+	}
+
+	if article.LastModified != "2023-01-01T10:15:00Z" {
+		t.Errorf("Expected last modified to be '2023-01-01T10:15:00Z', got '%s'", article.LastModified)
+	}
+}
+
+func TestParseWikipediaArticleWithoutHeading(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p>Search results</p></body></html>`))
+	if article := parseWikipediaArticle(doc.Selection); article != nil {
+		t.Errorf("Expected nil for a page without a heading, got %+v", article)
+	}
+}
+
+func TestIsWikipediaURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://en.wikipedia.org/wiki/Go":    true,
+		"https://de.m.wikipedia.org/wiki/Go":  true,
+		"https://wikipedia.org/":              true,
+		"https://notwikipedia.org/wiki/Go":    false,
+		"https://en.wikipedia.org.evil.com/x": false,
+	}
+
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := isWikipediaURL(u); got != want {
+			t.Errorf("isWikipediaURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
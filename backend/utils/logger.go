@@ -7,7 +7,8 @@ import (
 	"time"
 )
 
-// Logger provides a structured logging interface
+// Logger provides a structured logging interface. Secrets registered with
+// RegisterSecret are redacted from its output.
 type Logger struct {
 	*log.Logger
 	service string
@@ -23,18 +24,18 @@ func NewLogger(service string) *Logger {
 
 // Info logs an informational message
 func (l *Logger) Info(format string, v ...interface{}) {
-	l.Output(2, fmt.Sprintf("[INFO] [%s] %s", l.service, fmt.Sprintf(format, v...)))
+	l.Output(2, RedactSecrets(fmt.Sprintf("[INFO] [%s] %s", l.service, fmt.Sprintf(format, v...))))
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, v ...interface{}) {
-	l.Output(2, fmt.Sprintf("[ERROR] [%s] %s", l.service, fmt.Sprintf(format, v...)))
+	l.Output(2, RedactSecrets(fmt.Sprintf("[ERROR] [%s] %s", l.service, fmt.Sprintf(format, v...))))
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(format string, v ...interface{}) {
 	if os.Getenv("DEBUG") == "true" {
-		l.Output(2, fmt.Sprintf("[DEBUG] [%s] %s", l.service, fmt.Sprintf(format, v...)))
+		l.Output(2, RedactSecrets(fmt.Sprintf("[DEBUG] [%s] %s", l.service, fmt.Sprintf(format, v...))))
 	}
 }
 
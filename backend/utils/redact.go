@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in log output
+const Redacted = "[REDACTED]"
+
+// minSecretLength keeps very short values, which would match all over a log
+// line, from being registered
+const minSecretLength = 4
+
+var secrets = struct {
+	mu       sync.RWMutex
+	values   map[string]int // Registrations still holding each value
+	replacer *strings.Replacer
+}{values: make(map[string]int)}
+
+// RegisterSecret makes every Logger replace value with Redacted until the
+// returned func is called. A value registered more than once stays redacted
+// until each registration is undone.
+func RegisterSecret(value string) (unregister func()) {
+	if len(value) < minSecretLength {
+		return func() {}
+	}
+
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	secrets.values[value]++
+	if secrets.values[value] == 1 {
+		rebuildReplacer()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			secrets.mu.Lock()
+			defer secrets.mu.Unlock()
+			if secrets.values[value]--; secrets.values[value] <= 0 {
+				delete(secrets.values, value)
+				rebuildReplacer()
+			}
+		})
+	}
+}
+
+// rebuildReplacer recreates the replacer from the registered values. Callers
+// must hold secrets.mu.
+func rebuildReplacer() {
+	if len(secrets.values) == 0 {
+		secrets.replacer = nil
+		return
+	}
+
+	// Longest first, so a secret containing another is replaced whole
+	list := make([]string, 0, len(secrets.values))
+	for v := range secrets.values {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+
+	pairs := make([]string, 0, 2*len(list))
+	for _, v := range list {
+		pairs = append(pairs, v, Redacted)
+	}
+	secrets.replacer = strings.NewReplacer(pairs...)
+}
+
+// RedactSecrets replaces every registered secret in s with Redacted
+func RedactSecrets(s string) string {
+	secrets.mu.RLock()
+	replacer := secrets.replacer
+	secrets.mu.RUnlock()
+
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// redactingWriter redacts registered secrets from everything written through it
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter wraps w so registered secrets never reach it, e.g. to
+// cover the standard logger with log.SetOutput
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+// Write redacts p before passing it on. It reports len(p) on success, since
+// redaction changes the length actually written.
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, RedactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
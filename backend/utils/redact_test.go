@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	RegisterSecret("pa55word")
+	RegisterSecret("pa55word-and-more")
+	RegisterSecret("abc")
+
+	if got := RedactSecrets("login pa55word-and-more then pa55word, abc"); got != "login [REDACTED] then [REDACTED], abc" {
+		t.Errorf("Unexpected redaction: %q", got)
+	}
+
+	var buf bytes.Buffer
+	logger := &Logger{Logger: log.New(&buf, "", 0), service: "test"}
+	logger.Error("login failed for pa55word")
+	if got := buf.String(); got != "[ERROR] [test] login failed for [REDACTED]\n" {
+		t.Errorf("Expected the logger to redact secrets, got %q", got)
+	}
+
+	buf.Reset()
+	log.New(NewRedactingWriter(&buf), "", 0).Printf("token pa55word")
+	if got := buf.String(); got != "token [REDACTED]\n" {
+		t.Errorf("Expected the writer to redact secrets, got %q", got)
+	}
+}
+
+func TestUnregisterSecret(t *testing.T) {
+	first := RegisterSecret("t0ken-value")
+	second := RegisterSecret("t0ken-value")
+
+	first()
+	first()
+	if got := RedactSecrets("token t0ken-value"); got != "token [REDACTED]" {
+		t.Errorf("Expected the secret to stay redacted while registered twice, got %q", got)
+	}
+
+	second()
+	if got := RedactSecrets("token t0ken-value"); got != "token t0ken-value" {
+		t.Errorf("Expected the secret to be forgotten once unregistered, got %q", got)
+	}
+}